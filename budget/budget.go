@@ -0,0 +1,64 @@
+package budget
+
+import (
+	"fmt"
+
+	"github.com/d-bolshakov/orchestrator/task"
+)
+
+// DisruptionBudget limits how many tasks of a group may be taken down by
+// voluntary operations such as rebalancing or a manual stop. A task belongs
+// to the group when its labels contain every key/value pair of the selector.
+type DisruptionBudget struct {
+	Name           string
+	Selector       map[string]string
+	MaxUnavailable *int
+	MinAvailable   *int
+}
+
+func (b *DisruptionBudget) Matches(t *task.Task) bool {
+	if len(b.Selector) == 0 {
+		return false
+	}
+
+	for k, v := range b.Selector {
+		if t.Labels[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AllowEviction reports whether one more healthy task of the group can be
+// stopped, given the number of tasks that should be running and the number
+// of tasks that are currently healthy.
+func (b *DisruptionBudget) AllowEviction(expected int, healthy int) error {
+	if b.MinAvailable != nil && healthy-1 < *b.MinAvailable {
+		return fmt.Errorf("disruption budget %s requires at least %d healthy tasks, %d are healthy", b.Name, *b.MinAvailable, healthy)
+	}
+
+	unavailable := expected - healthy
+	if b.MaxUnavailable != nil && unavailable+1 > *b.MaxUnavailable {
+		return fmt.Errorf("disruption budget %s allows at most %d unavailable tasks, %d are unavailable", b.Name, *b.MaxUnavailable, unavailable)
+	}
+
+	return nil
+}
+
+func (b *DisruptionBudget) Validate() error {
+	if b.Name == "" {
+		return fmt.Errorf("disruption budget must have a name")
+	}
+	if len(b.Selector) == 0 {
+		return fmt.Errorf("disruption budget %s must have a selector", b.Name)
+	}
+	if b.MaxUnavailable == nil && b.MinAvailable == nil {
+		return fmt.Errorf("disruption budget %s must set MaxUnavailable or MinAvailable", b.Name)
+	}
+	if (b.MaxUnavailable != nil && *b.MaxUnavailable < 0) || (b.MinAvailable != nil && *b.MinAvailable < 0) {
+		return fmt.Errorf("disruption budget %s cannot have a negative MaxUnavailable or MinAvailable", b.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package budget
+
+import "testing"
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestValidate(t *testing.T) {
+	selector := map[string]string{"app": "web"}
+	tests := []struct {
+		name    string
+		budget  DisruptionBudget
+		wantErr bool
+	}{
+		{"max unavailable", DisruptionBudget{Name: "b", Selector: selector, MaxUnavailable: intPtr(1)}, false},
+		{"min available", DisruptionBudget{Name: "b", Selector: selector, MinAvailable: intPtr(2)}, false},
+		{"zero", DisruptionBudget{Name: "b", Selector: selector, MaxUnavailable: intPtr(0)}, false},
+		{"no name", DisruptionBudget{Selector: selector, MaxUnavailable: intPtr(1)}, true},
+		{"no selector", DisruptionBudget{Name: "b", MaxUnavailable: intPtr(1)}, true},
+		{"no limit", DisruptionBudget{Name: "b", Selector: selector}, true},
+		{"negative max unavailable", DisruptionBudget{Name: "b", Selector: selector, MaxUnavailable: intPtr(-1)}, true},
+		{"negative min available", DisruptionBudget{Name: "b", Selector: selector, MinAvailable: intPtr(-1)}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.budget.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAllowEviction(t *testing.T) {
+	tests := []struct {
+		name     string
+		budget   DisruptionBudget
+		expected int
+		healthy  int
+		wantErr  bool
+	}{
+		{"min available met", DisruptionBudget{MinAvailable: intPtr(2)}, 3, 3, false},
+		{"min available reached", DisruptionBudget{MinAvailable: intPtr(2)}, 3, 2, true},
+		{"max unavailable free", DisruptionBudget{MaxUnavailable: intPtr(1)}, 3, 3, false},
+		{"max unavailable used", DisruptionBudget{MaxUnavailable: intPtr(1)}, 3, 2, true},
+		{"max unavailable zero", DisruptionBudget{MaxUnavailable: intPtr(0)}, 3, 3, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.budget.AllowEviction(tt.expected, tt.healthy)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: AllowEviction(%d, %d) = %v, want error %v", tt.name, tt.expected, tt.healthy, err, tt.wantErr)
+		}
+	}
+}
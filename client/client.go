@@ -1,33 +1,45 @@
 package client
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/d-bolshakov/orchestrator/utils"
 	"github.com/d-bolshakov/orchestrator/worker"
 )
 
+// RequestTimeout bounds requests that get a plain answer. Streams, such as
+// logs, exec sessions and archives, last for as long as their context.
+const RequestTimeout = 10 * time.Second
+
 type Client struct {
 	address string
 	role    string
+	http    *http.Client
 }
 
 func (c *Client) GetTasks() ([]*task.Task, error) {
 	url := fmt.Sprintf("%s/tasks", c.address)
-	resp, err := http.Get(url)
+	resp, err := c.http.Get(url)
 	if err != nil {
 		log.Printf("Error connecting to %s %v: %v\n", c.role, c.address, err)
 		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("error sending request: %v\n", err)
-		return nil, err
+		return nil, decodeErrResponse(resp)
 	}
 
 	d := json.NewDecoder(resp.Body)
@@ -48,7 +60,7 @@ func (c *Client) SendTask(te task.TaskEvent) (*task.Task, error) {
 	}
 
 	url := fmt.Sprintf("%s/tasks", c.address)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+	resp, err := c.http.Post(url, "application/json", bytes.NewBuffer(data))
 	if err != nil {
 		log.Printf("Error connecting to %v: %v", c.address, err)
 		return nil, err
@@ -56,14 +68,7 @@ func (c *Client) SendTask(te task.TaskEvent) (*task.Task, error) {
 
 	d := json.NewDecoder(resp.Body)
 	if resp.StatusCode != http.StatusCreated {
-		e := worker.ErrResponse{}
-		err := d.Decode(&e)
-		if err != nil {
-			log.Printf("Error decoding response: %s\n", err.Error())
-			return nil, err
-		}
-		log.Printf("Response error (%d): %s\n", e.HTTPStatusCode, e.Message)
-		return nil, err
+		return nil, decodeErrResponse(resp)
 	}
 
 	newTask := task.Task{}
@@ -76,33 +81,245 @@ func (c *Client) SendTask(te task.TaskEvent) (*task.Task, error) {
 }
 
 func (c *Client) StopTask(taskID string) error {
-	client := &http.Client{}
 	url := fmt.Sprintf("%s/tasks/%s", c.address, taskID)
+	return c.stopTask(url, taskID)
+}
+
+func (c *Client) stopTask(url string, taskID string) error {
 	req, err := http.NewRequest("DELETE", url, nil)
 	if err != nil {
 		log.Printf("error creating request to delete task %s: %v\n", taskID, err)
 		return err
 	}
 
-	resp, err := client.Do(req)
+	resp, err := c.http.Do(req)
 	if err != nil {
 		log.Printf("error connecting to %s at %s: %v\n", c.role, c.address, err)
 		return err
 	}
 
 	if resp.StatusCode != 204 {
-		log.Printf("Error sending request: %v\n", err)
-		return err
+		return decodeErrResponse(resp)
 	}
 
 	log.Printf("task %s has been scheduled to be stopped", taskID)
 	return nil
 }
 
+// PauseTask freezes the container of a running task.
+func (c *Client) PauseTask(taskID string) error {
+	return c.taskAction(taskID, "pause")
+}
+
+// ResumeTask thaws the container of a paused task.
+func (c *Client) ResumeTask(taskID string) error {
+	return c.taskAction(taskID, "resume")
+}
+
+// RemoveTask has a worker forget a task that stopped running there and
+// remove its containers.
+func (c *Client) RemoveTask(taskID string) error {
+	return c.taskAction(taskID, "remove")
+}
+
+func (c *Client) taskAction(taskID string, action string) error {
+	url := fmt.Sprintf("%s/tasks/%s/%s", c.address, taskID, action)
+	resp, err := c.http.Post(url, "application/json", nil)
+	if err != nil {
+		log.Printf("error connecting to %s at %s: %v\n", c.role, c.address, err)
+		return err
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		return decodeErrResponse(resp)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// TaskLogs opens the log stream of a task. The caller has to close it, which
+// also ends a followed stream, as does cancelling the context.
+func (c *Client) TaskLogs(ctx context.Context, taskID string, opts task.LogOptions) (io.ReadCloser, error) {
+	url := fmt.Sprintf("%s/tasks/%s/logs?%s", c.address, taskID, opts.Query().Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		log.Printf("error creating request for logs of task %s: %v\n", taskID, err)
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("error connecting to %s at %s: %v\n", c.role, c.address, err)
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeErrResponse(resp)
+	}
+
+	return resp.Body, nil
+}
+
+func (c *Client) CopyFromTask(ctx context.Context, taskID string, container string, srcPath string) (io.ReadCloser, error) {
+	q := url.Values{"path": {srcPath}}
+	if container != "" {
+		q.Set("container", container)
+	}
+	url := fmt.Sprintf("%s/tasks/%s/archive?%s", c.address, taskID, q.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		log.Printf("error creating request to copy from task %s: %v\n", taskID, err)
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("error connecting to %s at %s: %v\n", c.role, c.address, err)
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeErrResponse(resp)
+	}
+
+	return resp.Body, nil
+}
+
+func (c *Client) CopyToTask(ctx context.Context, taskID string, container string, dstPath string, archive io.Reader) error {
+	q := url.Values{"path": {dstPath}}
+	if container != "" {
+		q.Set("container", container)
+	}
+	url := fmt.Sprintf("%s/tasks/%s/archive?%s", c.address, taskID, q.Encode())
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, archive)
+	if err != nil {
+		log.Printf("error creating request to copy to task %s: %v\n", taskID, err)
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("error connecting to %s at %s: %v\n", c.role, c.address, err)
+		return err
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		return decodeErrResponse(resp)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// ExecSession is a command running in a task, attached through an upgraded
+// connection: stdin is written to it and output read from it.
+type ExecSession struct {
+	ID string
+	utils.Stream
+}
+
+func (c *Client) Exec(taskID string, opts task.ExecOptions) (*ExecSession, error) {
+	url := fmt.Sprintf("%s/tasks/%s/exec?%s", c.address, taskID, opts.Query().Encode())
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		log.Printf("error creating request to run command in task %s: %v\n", taskID, err)
+		return nil, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	conn, err := net.Dial("tcp", req.URL.Host)
+	if err != nil {
+		log.Printf("error connecting to %s at %s: %v\n", c.role, c.address, err)
+		return nil, err
+	}
+
+	err = req.Write(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer conn.Close()
+		return nil, decodeErrResponse(resp)
+	}
+
+	return &ExecSession{
+		ID:     resp.Header.Get("X-Exec-Id"),
+		Stream: utils.Stream{Conn: conn, Reader: reader},
+	}, nil
+}
+
+func (c *Client) ResizeExec(taskID string, execID string, height uint, width uint) error {
+	url := fmt.Sprintf("%s/tasks/%s/exec/%s/resize?h=%d&w=%d", c.address, taskID, execID, height, width)
+	resp, err := c.http.Post(url, "application/json", nil)
+	if err != nil {
+		log.Printf("error connecting to %s at %s: %v\n", c.role, c.address, err)
+		return err
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		return decodeErrResponse(resp)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+func (c *Client) InspectExec(taskID string, execID string) (task.ExecStatus, error) {
+	status := task.ExecStatus{}
+	url := fmt.Sprintf("%s/tasks/%s/exec/%s", c.address, taskID, execID)
+	resp, err := c.http.Get(url)
+	if err != nil {
+		log.Printf("error connecting to %s at %s: %v\n", c.role, c.address, err)
+		return status, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return status, decodeErrResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	if err != nil {
+		log.Printf("error decoding response: %v\n", err)
+		return status, err
+	}
+
+	return status, nil
+}
+
+// decodeErrResponse turns an unsuccessful response into an error, using the
+// message from the ErrResponse body when there is one.
+func decodeErrResponse(resp *http.Response) error {
+	defer resp.Body.Close()
+
+	e := worker.ErrResponse{}
+	err := json.NewDecoder(resp.Body).Decode(&e)
+	if err != nil || e.Message == "" {
+		err := fmt.Errorf("unexpected response status %d", resp.StatusCode)
+		log.Printf("Response error: %v\n", err)
+		return err
+	}
+
+	log.Printf("Response error (%d): %s\n", e.HTTPStatusCode, e.Message)
+	return errors.New(e.Message)
+}
+
 func New(address string, role string) *Client {
 	return &Client{
 		address: toHttpUrl(address),
 		role:    role,
+		http:    &http.Client{Timeout: RequestTimeout},
 	}
 }
 
@@ -1,13 +1,19 @@
 package client
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 
+	"github.com/d-bolshakov/orchestrator/budget"
+	"github.com/d-bolshakov/orchestrator/job"
 	"github.com/d-bolshakov/orchestrator/node"
+	"github.com/d-bolshakov/orchestrator/service"
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/d-bolshakov/orchestrator/workflow"
 )
 
 type ManagerClient struct {
@@ -15,8 +21,8 @@ type ManagerClient struct {
 }
 
 func (mc *ManagerClient) GetNodes() ([]*node.Node, error) {
-	url := fmt.Sprintf("http://%s/nodes", mc.address)
-	resp, err := http.Get(url)
+	url := fmt.Sprintf("%s/nodes", mc.address)
+	resp, err := mc.http.Get(url)
 	if err != nil {
 		log.Printf("Error connecting to %s: %v", mc.address, err)
 		return nil, err
@@ -34,11 +40,229 @@ func (mc *ManagerClient) GetNodes() ([]*node.Node, error) {
 	return nodes, nil
 }
 
+func (mc *ManagerClient) GetTask(taskID string) (*task.Task, error) {
+	var t task.Task
+	err := mc.getJSON(fmt.Sprintf("%s/tasks/%s", mc.address, taskID), &t)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ForceStopTask stops a task even if doing so violates a disruption budget.
+func (mc *ManagerClient) ForceStopTask(taskID string) error {
+	url := fmt.Sprintf("%s/tasks/%s?force=true", mc.address, taskID)
+	return mc.stopTask(url, taskID)
+}
+
+func (mc *ManagerClient) CreateBudget(b budget.DisruptionBudget) error {
+	return mc.postJSON(fmt.Sprintf("%s/budgets", mc.address), b, http.StatusCreated)
+}
+
+func (mc *ManagerClient) GetBudgets() ([]*budget.DisruptionBudget, error) {
+	var budgets []*budget.DisruptionBudget
+	err := mc.getJSON(fmt.Sprintf("%s/budgets", mc.address), &budgets)
+	return budgets, err
+}
+
+func (mc *ManagerClient) DeleteBudget(name string) error {
+	return mc.delete(fmt.Sprintf("%s/budgets/%s", mc.address, name))
+}
+
+func (mc *ManagerClient) CreateService(s service.Service) error {
+	return mc.postJSON(fmt.Sprintf("%s/services", mc.address), s, http.StatusCreated)
+}
+
+func (mc *ManagerClient) GetServices() ([]*service.Service, error) {
+	var services []*service.Service
+	err := mc.getJSON(fmt.Sprintf("%s/services", mc.address), &services)
+	return services, err
+}
+
+func (mc *ManagerClient) GetService(name string) (*service.Service, error) {
+	var s service.Service
+	err := mc.getJSON(fmt.Sprintf("%s/services/%s", mc.address, name), &s)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (mc *ManagerClient) UpdateService(s service.Service) error {
+	url := fmt.Sprintf("%s/services/%s", mc.address, s.Name)
+	return mc.sendJSON("PUT", url, s, http.StatusNoContent)
+}
+
+func (mc *ManagerClient) ScaleService(name string, replicas int) error {
+	url := fmt.Sprintf("%s/services/%s/scale", mc.address, name)
+	return mc.postJSON(url, map[string]int{"Replicas": replicas}, http.StatusNoContent)
+}
+
+func (mc *ManagerClient) GetServiceHistory(name string) ([]*service.Revision, error) {
+	var history []*service.Revision
+	err := mc.getJSON(fmt.Sprintf("%s/services/%s/history", mc.address, name), &history)
+	return history, err
+}
+
+// RollbackService rolls the service back to the given revision, or to the
+// previous one if revision is 0.
+func (mc *ManagerClient) RollbackService(name string, revision int, author string) error {
+	url := fmt.Sprintf("%s/services/%s/rollback", mc.address, name)
+	body := map[string]any{"Revision": revision, "Author": author}
+	return mc.postJSON(url, body, http.StatusNoContent)
+}
+
+func (mc *ManagerClient) PromoteService(name string) error {
+	url := fmt.Sprintf("%s/services/%s/promote", mc.address, name)
+	return mc.postJSON(url, nil, http.StatusNoContent)
+}
+
+func (mc *ManagerClient) AbortService(name string) error {
+	url := fmt.Sprintf("%s/services/%s/abort", mc.address, name)
+	return mc.postJSON(url, nil, http.StatusNoContent)
+}
+
+func (mc *ManagerClient) DeleteService(name string) error {
+	return mc.delete(fmt.Sprintf("%s/services/%s", mc.address, name))
+}
+
+// ForceDeleteService removes a service even if stopping its tasks violates a
+// disruption budget.
+func (mc *ManagerClient) ForceDeleteService(name string) error {
+	return mc.delete(fmt.Sprintf("%s/services/%s?force=true", mc.address, name))
+}
+
+func (mc *ManagerClient) CreateJob(j job.Job) error {
+	return mc.postJSON(fmt.Sprintf("%s/jobs", mc.address), j, http.StatusCreated)
+}
+
+func (mc *ManagerClient) GetJobs() ([]*job.Job, error) {
+	var jobs []*job.Job
+	err := mc.getJSON(fmt.Sprintf("%s/jobs", mc.address), &jobs)
+	return jobs, err
+}
+
+func (mc *ManagerClient) GetJob(name string) (*job.Job, error) {
+	var j job.Job
+	err := mc.getJSON(fmt.Sprintf("%s/jobs/%s", mc.address, name), &j)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (mc *ManagerClient) DeleteJob(name string) error {
+	return mc.delete(fmt.Sprintf("%s/jobs/%s", mc.address, name))
+}
+
+func (mc *ManagerClient) CreateCronJob(c job.CronJob) error {
+	return mc.postJSON(fmt.Sprintf("%s/cronjobs", mc.address), c, http.StatusCreated)
+}
+
+func (mc *ManagerClient) GetCronJobs() ([]*job.CronJob, error) {
+	var cronJobs []*job.CronJob
+	err := mc.getJSON(fmt.Sprintf("%s/cronjobs", mc.address), &cronJobs)
+	return cronJobs, err
+}
+
+func (mc *ManagerClient) DeleteCronJob(name string) error {
+	return mc.delete(fmt.Sprintf("%s/cronjobs/%s", mc.address, name))
+}
+
+func (mc *ManagerClient) CreateWorkflow(w workflow.Workflow) error {
+	return mc.postJSON(fmt.Sprintf("%s/workflows", mc.address), w, http.StatusCreated)
+}
+
+func (mc *ManagerClient) GetWorkflows() ([]*workflow.Workflow, error) {
+	var workflows []*workflow.Workflow
+	err := mc.getJSON(fmt.Sprintf("%s/workflows", mc.address), &workflows)
+	return workflows, err
+}
+
+func (mc *ManagerClient) GetWorkflow(name string) (*workflow.Workflow, error) {
+	var w workflow.Workflow
+	err := mc.getJSON(fmt.Sprintf("%s/workflows/%s", mc.address, name), &w)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (mc *ManagerClient) DeleteWorkflow(name string) error {
+	return mc.delete(fmt.Sprintf("%s/workflows/%s", mc.address, name))
+}
+
+func (mc *ManagerClient) postJSON(url string, body any, expectedStatus int) error {
+	return mc.sendJSON("POST", url, body, expectedStatus)
+}
+
+func (mc *ManagerClient) sendJSON(method string, url string, body any, expectedStatus int) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		log.Printf("Unable to marshal request body: %v.", body)
+		return err
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(data))
+	if err != nil {
+		log.Printf("Error creating request to %s: %v\n", url, err)
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := mc.http.Do(req)
+	if err != nil {
+		log.Printf("Error connecting to %v: %v", mc.address, err)
+		return err
+	}
+
+	if resp.StatusCode != expectedStatus {
+		return decodeErrResponse(resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (mc *ManagerClient) getJSON(url string, out any) error {
+	resp, err := mc.http.Get(url)
+	if err != nil {
+		log.Printf("Error connecting to %s: %v", mc.address, err)
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return decodeErrResponse(resp)
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		log.Printf("Error unmarshalling response from %s: %v\n", url, err)
+		return err
+	}
+	return nil
+}
+
+func (mc *ManagerClient) delete(url string) error {
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		log.Printf("Error creating request to %s: %v\n", url, err)
+		return err
+	}
+
+	resp, err := mc.http.Do(req)
+	if err != nil {
+		log.Printf("Error connecting to %s: %v\n", mc.address, err)
+		return err
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		return decodeErrResponse(resp)
+	}
+	return nil
+}
+
 func NewManagerClient(address string) *ManagerClient {
 	return &ManagerClient{
-		Client: Client{
-			address: address,
-			role:    "manager",
-		},
+		Client: *New(address, "manager"),
 	}
 }
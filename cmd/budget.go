@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/d-bolshakov/orchestrator/budget"
+	"github.com/d-bolshakov/orchestrator/client"
+	"github.com/spf13/cobra"
+)
+
+var budgetCmd = &cobra.Command{
+	Use:   "budget",
+	Short: "Manage disruption budgets",
+	Long: `orchestrator budget command.
+
+Disruption budgets limit how many tasks of a group can be stopped by
+voluntary operations such as rebalancing or a manual stop.`,
+}
+
+var budgetCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create or replace a disruption budget",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		selector, _ := cmd.Flags().GetStringToString("selector")
+
+		b := budget.DisruptionBudget{
+			Name:     args[0],
+			Selector: selector,
+		}
+		if cmd.Flags().Changed("max-unavailable") {
+			maxUnavailable, _ := cmd.Flags().GetInt("max-unavailable")
+			b.MaxUnavailable = &maxUnavailable
+		}
+		if cmd.Flags().Changed("min-available") {
+			minAvailable, _ := cmd.Flags().GetInt("min-available")
+			b.MinAvailable = &minAvailable
+		}
+
+		err := b.Validate()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		client := client.NewManagerClient(manager)
+		err = client.CreateBudget(b)
+		if err != nil {
+			log.Fatalf("Error creating disruption budget: %v", err)
+		}
+
+		log.Printf("Disruption budget %s has been created.", b.Name)
+	},
+}
+
+var budgetListCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List disruption budgets",
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		budgets, err := client.GetBudgets()
+		if err != nil {
+			log.Fatalf("Error retrieving the list of disruption budgets from the manager: %v", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "NAME\tSELECTOR\tMIN AVAILABLE\tMAX UNAVAILABLE\t")
+		for _, b := range budgets {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", b.Name, formatLabels(b.Selector), formatOptionalInt(b.MinAvailable), formatOptionalInt(b.MaxUnavailable))
+		}
+		w.Flush()
+	},
+}
+
+var budgetRemoveCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Short: "Remove a disruption budget",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		err := client.DeleteBudget(args[0])
+		if err != nil {
+			log.Fatalf("Error removing disruption budget: %v", err)
+		}
+
+		log.Printf("Disruption budget %s has been removed.", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(budgetCmd)
+	budgetCmd.AddCommand(budgetCreateCmd, budgetListCmd, budgetRemoveCmd)
+
+	budgetCmd.PersistentFlags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	budgetCreateCmd.Flags().StringToStringP("selector", "l", nil, "Labels selecting the tasks covered by the budget (e.g. app=web)")
+	budgetCreateCmd.Flags().Int("max-unavailable", 0, "Maximum number of tasks that may be unavailable")
+	budgetCreateCmd.Flags().Int("min-available", 0, "Minimum number of tasks that must stay healthy")
+}
+
+func formatLabels(labels map[string]string) string {
+	pairs := []string{}
+	for k, v := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, v))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func formatOptionalInt(v *int) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%d", *v)
+}
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/d-bolshakov/orchestrator/client"
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/spf13/cobra"
+)
+
+var cpCmd = &cobra.Command{
+	Use:   "cp <task>:<path> <local path> | <local path> <task>:<path>",
+	Short: "Copy files from or to a task",
+	Long: `orchestrator cp command.
+
+The cp command copies a file or directory from a container of a task to the
+local machine, or the other way around. Like cp, a copy into an existing
+directory lands inside it, and otherwise takes the name of the destination.
+A local path of - writes the files to stdout, or reads them from stdin, as a
+tar archive.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		container, _ := cmd.Flags().GetString("container")
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		c := client.New(manager, "manager")
+		srcTask, srcPath, srcRemote := splitTaskPath(args[0])
+		dstTask, dstPath, dstRemote := splitTaskPath(args[1])
+		switch {
+		case srcRemote && !dstRemote:
+			archive, err := c.CopyFromTask(ctx, srcTask, container, srcPath)
+			if err != nil {
+				log.Fatalf("Error copying from the task: %v", err)
+			}
+			defer archive.Close()
+
+			err = extractLocal(archive, dstPath)
+			if err != nil {
+				log.Fatalf("Error copying from the task: %v", err)
+			}
+		case !srcRemote && dstRemote:
+			archive, err := archiveLocal(srcPath)
+			if err != nil {
+				log.Fatalf("Error copying to the task: %v", err)
+			}
+			defer archive.Close()
+
+			err = c.CopyToTask(ctx, dstTask, container, dstPath, archive)
+			if err != nil {
+				log.Fatalf("Error copying to the task: %v", err)
+			}
+		default:
+			log.Fatalf("Exactly one of the paths must be in a task, written as <task>:<path>")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cpCmd)
+
+	cpCmd.Flags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	cpCmd.Flags().StringP("container", "c", "", "Copy from or to the sidecar with this name")
+}
+
+// splitTaskPath splits an argument of the form <task>:<path>. Local paths
+// starting with / or ., or with a Windows drive letter like C:, are never
+// taken for paths in a task.
+func splitTaskPath(arg string) (string, string, bool) {
+	if strings.HasPrefix(arg, "/") || strings.HasPrefix(arg, ".") || filepath.VolumeName(arg) != "" {
+		return "", arg, false
+	}
+
+	taskID, p, found := strings.Cut(arg, ":")
+	if !found || taskID == "" {
+		return "", arg, false
+	}
+
+	return taskID, p, true
+}
+
+// archiveLocal returns a tar archive of a local file or directory, named
+// after its last element.
+func archiveLocal(src string) (io.ReadCloser, error) {
+	if src == "-" {
+		return os.Stdin, nil
+	}
+
+	src = filepath.Clean(src)
+	_, err := os.Lstat(src)
+	if err != nil {
+		return nil, err
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(w)
+		err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			link := ""
+			if info.Mode()&os.ModeSymlink != 0 {
+				link, err = os.Readlink(p)
+				if err != nil {
+					return err
+				}
+			}
+			hdr, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(filepath.Dir(src), p)
+			if err != nil {
+				return err
+			}
+			hdr.Name = filepath.ToSlash(rel)
+			if info.IsDir() {
+				hdr.Name += "/"
+			}
+
+			err = tw.WriteHeader(hdr)
+			if err != nil || !info.Mode().IsRegular() {
+				return err
+			}
+
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(tw, f)
+			return err
+		})
+		if err == nil {
+			err = tw.Close()
+		}
+		w.CloseWithError(err)
+	}()
+
+	return r, nil
+}
+
+// extractLocal extracts a tar archive holding a single file or directory to
+// dst: into it if it is an existing directory, and in its place otherwise.
+func extractLocal(archive io.Reader, dst string) error {
+	if dst == "-" {
+		_, err := io.Copy(os.Stdout, archive)
+		return err
+	}
+
+	dir := dst
+	info, err := os.Stat(dst)
+	if err != nil || !info.IsDir() {
+		dir = filepath.Dir(dst)
+		renamed := task.RenameArchiveRoot(archive, filepath.Base(dst))
+		defer renamed.Close()
+		archive = renamed
+	}
+
+	return extractArchive(archive, dir)
+}
+
+// extractArchive extracts a tar archive into dir, refusing entries that
+// would land or link outside of it.
+func extractArchive(archive io.Reader, dir string) error {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+
+	inside := func(p string) bool {
+		return p == root || strings.HasPrefix(p, root+string(filepath.Separator))
+	}
+	within := func(p string) error {
+		parent, err := filepath.EvalSymlinks(filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		if !inside(parent) {
+			return fmt.Errorf("archive entry %s is outside of %s", p, dir)
+		}
+		return nil
+	}
+
+	tr := tar.NewReader(archive)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(root, filepath.FromSlash(hdr.Name))
+		if target == root {
+			continue
+		}
+		err = within(target)
+		if err != nil {
+			return err
+		}
+		// An entry replaces a symlink of the same name rather than following
+		// it, which could lead anywhere.
+		err = removeSymlink(target)
+		if err != nil {
+			return err
+		}
+
+		mode := os.FileMode(hdr.Mode).Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, mode)
+		case tar.TypeReg:
+			err = writeFile(target, mode, tr)
+		case tar.TypeSymlink:
+			dest := filepath.FromSlash(hdr.Linkname)
+			if !filepath.IsAbs(dest) {
+				dest = filepath.Join(filepath.Dir(target), dest)
+			}
+			if !inside(filepath.Clean(dest)) {
+				return fmt.Errorf("archive entry %s links to %s, outside of %s", hdr.Name, hdr.Linkname, dir)
+			}
+			os.Remove(target)
+			err = os.Symlink(hdr.Linkname, target)
+		case tar.TypeLink:
+			source := filepath.Join(root, filepath.FromSlash(hdr.Linkname))
+			err = within(source)
+			if err == nil {
+				os.Remove(target)
+				err = os.Link(source, target)
+			}
+		default:
+			log.Printf("Skipping %s, unsupported type of file\n", hdr.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// removeSymlink removes the file at p if it is a symlink.
+func removeSymlink(p string) error {
+	info, err := os.Lstat(p)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+
+	return os.Remove(p)
+}
+
+func writeFile(p string, mode os.FileMode, r io.Reader) error {
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
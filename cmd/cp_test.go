@@ -0,0 +1,27 @@
+package cmd
+
+import "testing"
+
+func TestSplitTaskPath(t *testing.T) {
+	tests := []struct {
+		arg      string
+		wantTask string
+		wantPath string
+		wantOK   bool
+	}{
+		{"web:/etc/nginx.conf", "web", "/etc/nginx.conf", true},
+		{"8f1c2d3e:data", "8f1c2d3e", "data", true},
+		{"web:", "web", "", true},
+		{"notes.txt", "", "notes.txt", false},
+		{"/tmp/a:b", "", "/tmp/a:b", false},
+		{"./a:b", "", "./a:b", false},
+		{":data", "", ":data", false},
+	}
+
+	for _, tt := range tests {
+		task, path, ok := splitTaskPath(tt.arg)
+		if task != tt.wantTask || path != tt.wantPath || ok != tt.wantOK {
+			t.Errorf("splitTaskPath(%q) = %q, %q, %v, want %q, %q, %v", tt.arg, task, path, ok, tt.wantTask, tt.wantPath, tt.wantOK)
+		}
+	}
+}
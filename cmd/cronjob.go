@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/client"
+	"github.com/d-bolshakov/orchestrator/job"
+	"github.com/spf13/cobra"
+)
+
+var cronJobCmd = &cobra.Command{
+	Use:   "cronjob",
+	Short: "Manage cron jobs",
+	Long: `orchestrator cronjob command.
+
+A cron job creates a job from its template every time its cron schedule
+fires, in the configured time zone.`,
+}
+
+var cronJobCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a cron job from a specification file",
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		filename, _ := cmd.Flags().GetString("filename")
+
+		var c job.CronJob
+		readSpecFile(filename, &c)
+
+		client := client.NewManagerClient(manager)
+		err := client.CreateCronJob(c)
+		if err != nil {
+			log.Fatalf("Error creating cron job: %v", err)
+		}
+
+		log.Printf("Cron job %s has been created.", c.Name)
+	},
+}
+
+var cronJobListCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List cron jobs",
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		cronJobs, err := client.GetCronJobs()
+		if err != nil {
+			log.Fatalf("Error retrieving the list of cron jobs from the manager: %v", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "NAME\tSCHEDULE\tTIMEZONE\tCONCURRENCY\tLAST RUN\tNEXT RUN\tMESSAGE\t")
+		for _, c := range cronJobs {
+			spec := c.WithDefaults()
+			tz := c.TimeZone
+			if tz == "" {
+				tz = "UTC"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", c.Name, c.Schedule, tz, spec.ConcurrencyPolicy, formatTime(c.LastScheduleTime), formatTime(c.NextScheduleTime), c.Message)
+		}
+		w.Flush()
+	},
+}
+
+var cronJobRemoveCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Short: "Remove a cron job and its jobs",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		err := client.DeleteCronJob(args[0])
+		if err != nil {
+			log.Fatalf("Error removing cron job: %v", err)
+		}
+
+		log.Printf("Cron job %s has been removed.", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cronJobCmd)
+	cronJobCmd.AddCommand(cronJobCreateCmd, cronJobListCmd, cronJobRemoveCmd)
+
+	cronJobCmd.PersistentFlags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	cronJobCreateCmd.Flags().StringP("filename", "f", "cronjob.json", "Cron job specification file")
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/d-bolshakov/orchestrator/client"
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/spf13/cobra"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec [-it] <task> -- <command> [args...]",
+	Short: "Run a command in a running task",
+	Long: `orchestrator exec command.
+
+The exec command runs a command in the container of a running task, through
+the manager and the worker running the task. With --interactive the input of
+the command is read from stdin, and with --tty the command gets a terminal,
+resized along with the local one. The exec command exits with the exit code
+of the command.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		opts := task.ExecOptions{Cmd: args[1:]}
+		opts.Stdin, _ = cmd.Flags().GetBool("interactive")
+		opts.Tty, _ = cmd.Flags().GetBool("tty")
+		opts.Container, _ = cmd.Flags().GetString("container")
+
+		stdinFd := int(os.Stdin.Fd())
+		stdoutFd := int(os.Stdout.Fd())
+		if opts.Tty {
+			if !isTerminal(stdoutFd) {
+				log.Fatalf("The --tty flag needs stdout to be a terminal")
+			}
+			opts.Height, opts.Width, _ = terminalSize(stdoutFd)
+		}
+
+		c := client.New(manager, "manager")
+		session, err := c.Exec(args[0], opts)
+		if err != nil {
+			log.Fatalf("Error running the command: %v", err)
+		}
+		defer session.Close()
+
+		restore := func() {}
+		if opts.Tty {
+			if opts.Stdin && isTerminal(stdinFd) {
+				restore, err = makeRaw(stdinFd)
+				if err != nil {
+					log.Fatalf("Error setting up the terminal: %v", err)
+				}
+			}
+
+			resized := notifyResize()
+			go func() {
+				for range resized {
+					height, width, err := terminalSize(stdoutFd)
+					if err == nil {
+						c.ResizeExec(args[0], session.ID, height, width)
+					}
+				}
+			}()
+		}
+
+		if opts.Stdin {
+			go func() {
+				io.Copy(session, os.Stdin)
+				session.CloseWrite()
+			}()
+		}
+
+		if opts.Tty {
+			io.Copy(os.Stdout, session)
+		} else {
+			stdcopy.StdCopy(os.Stdout, os.Stderr, session)
+		}
+		restore()
+
+		status, err := c.InspectExec(args[0], session.ID)
+		if err != nil {
+			log.Fatalf("Error retrieving the exit code of the command: %v", err)
+		}
+		os.Exit(status.ExitCode)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+
+	execCmd.Flags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	execCmd.Flags().BoolP("interactive", "i", false, "Pass stdin to the command")
+	execCmd.Flags().BoolP("tty", "t", false, "Run the command in a terminal")
+	execCmd.Flags().StringP("container", "c", "", "Run the command in the sidecar with this name")
+}
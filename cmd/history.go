@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/d-bolshakov/orchestrator/client"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history <task>",
+	Short: "Show the state transitions of a task",
+	Long: `orchestrator history command.
+
+The history command lists the states a task went through, oldest first,
+with the reason for each transition.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		t, err := client.GetTask(args[0])
+		if err != nil {
+			log.Fatalf("Error retrieving task: %v", err)
+		}
+
+		fmt.Printf("Task %s is %s", t.ID, t.State)
+		if t.Reason != "" {
+			fmt.Printf(" (%s)", t.Reason)
+		}
+		if t.Message != "" {
+			fmt.Printf(": %s", t.Message)
+		}
+		fmt.Println()
+		fmt.Println()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "TIME\tFROM\tTO\tREASON\tMESSAGE\t")
+		for _, tr := range t.Transitions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", formatTime(tr.Time), tr.From, tr.To, tr.Reason, tr.Message)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+
+	historyCmd.Flags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/client"
+	"github.com/d-bolshakov/orchestrator/job"
+	"github.com/spf13/cobra"
+)
+
+var jobCmd = &cobra.Command{
+	Use:   "job",
+	Short: "Manage batch jobs",
+	Long: `orchestrator job command.
+
+A job runs tasks from a template to completion. A task succeeds when its
+container exits with code 0, failed tasks are retried with an exponential
+backoff until the backoff limit of the job is reached.
+
+An indexed job, with a Count or a list of Parameters, runs one task per
+index and succeeds once every index has succeeded. Each task receives its
+index in JOB_INDEX and the parameter set of its index as environment
+variables.`,
+}
+
+var jobCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a job from a specification file",
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		filename, _ := cmd.Flags().GetString("filename")
+
+		var j job.Job
+		readSpecFile(filename, &j)
+
+		client := client.NewManagerClient(manager)
+		err := client.CreateJob(j)
+		if err != nil {
+			log.Fatalf("Error creating job: %v", err)
+		}
+
+		log.Printf("Job %s has been created.", j.Name)
+	},
+}
+
+var jobListCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List jobs",
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		jobs, err := client.GetJobs()
+		if err != nil {
+			log.Fatalf("Error retrieving the list of jobs from the manager: %v", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "NAME\tIMAGE\tCOMPLETIONS\tACTIVE\tFAILED\tSTATE\t")
+		for _, j := range jobs {
+			spec := j.WithDefaults()
+			fmt.Fprintf(w, "%s\t%s\t%d/%d\t%d\t%d\t%s\t\n", j.Name, j.Template.Image, j.Status.Succeeded, spec.Completions, j.Status.Active, j.Status.Failed, j.Status.State)
+		}
+		w.Flush()
+	},
+}
+
+var jobStatusCmd = &cobra.Command{
+	Use:   "status <name>",
+	Short: "Show the status of a job",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		j, err := client.GetJob(args[0])
+		if err != nil {
+			log.Fatalf("Error retrieving job: %v", err)
+		}
+
+		spec := j.WithDefaults()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Name:\t%s\n", j.Name)
+		fmt.Fprintf(w, "Image:\t%s\n", j.Template.Image)
+		fmt.Fprintf(w, "State:\t%s\n", j.Status.State)
+		fmt.Fprintf(w, "Completions:\t%d/%d\n", j.Status.Succeeded, spec.Completions)
+		if spec.Indexed() {
+			fmt.Fprintf(w, "Indexes:\t%d\n", spec.Indexes())
+			fmt.Fprintf(w, "Succeeded indexes:\t%s\n", formatIndexes(j.Status.SucceededIndexes))
+			fmt.Fprintf(w, "Failed indexes:\t%s\n", formatIndexes(j.Status.FailedIndexes))
+		}
+		fmt.Fprintf(w, "Parallelism:\t%d\n", spec.Parallelism)
+		fmt.Fprintf(w, "Active:\t%d\n", j.Status.Active)
+		fmt.Fprintf(w, "Failed:\t%d (backoff limit %d)\n", j.Status.Failed, *spec.BackoffLimit)
+		fmt.Fprintf(w, "Started:\t%s\n", j.Status.StartTime.Local().Format(time.DateTime))
+		if !j.Status.CompletionTime.IsZero() {
+			fmt.Fprintf(w, "Finished:\t%s\n", j.Status.CompletionTime.Local().Format(time.DateTime))
+		}
+		if j.Status.Message != "" {
+			fmt.Fprintf(w, "Message:\t%s\n", j.Status.Message)
+		}
+		w.Flush()
+	},
+}
+
+var jobRemoveCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Short: "Remove a job and stop its running tasks",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		err := client.DeleteJob(args[0])
+		if err != nil {
+			log.Fatalf("Error removing job: %v", err)
+		}
+
+		log.Printf("Job %s has been removed.", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(jobCmd)
+	jobCmd.AddCommand(jobCreateCmd, jobListCmd, jobStatusCmd, jobRemoveCmd)
+
+	jobCmd.PersistentFlags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	jobCreateCmd.Flags().StringP("filename", "f", "job.json", "Job specification file")
+}
+
+func formatIndexes(indexes string) string {
+	if indexes == "" {
+		return "-"
+	}
+	return indexes
+}
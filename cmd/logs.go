@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/d-bolshakov/orchestrator/client"
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/spf13/cobra"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs <task>",
+	Short: "Print the logs of a task",
+	Long: `orchestrator logs command.
+
+The logs command prints the logs of a task, stdout and stderr interleaved.
+With --follow it keeps printing new logs until interrupted or the task
+stops.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		opts := task.LogOptions{}
+		opts.Follow, _ = cmd.Flags().GetBool("follow")
+		opts.Tail, _ = cmd.Flags().GetString("tail")
+		opts.Since, _ = cmd.Flags().GetString("since")
+		opts.Timestamps, _ = cmd.Flags().GetBool("timestamps")
+		opts.Container, _ = cmd.Flags().GetString("container")
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		logs, err := client.New(manager, "manager").TaskLogs(ctx, args[0], opts)
+		if err != nil {
+			log.Fatalf("Error retrieving the logs of the task: %v", err)
+		}
+		defer logs.Close()
+
+		io.Copy(os.Stdout, logs)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+
+	logsCmd.Flags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	logsCmd.Flags().BoolP("follow", "f", false, "Keep printing new logs")
+	logsCmd.Flags().String("tail", "all", "Number of lines to print from the end of the logs")
+	logsCmd.Flags().String("since", "", "Print logs since a timestamp (e.g. 2024-01-02T15:04:05Z) or for a duration (e.g. 10m)")
+	logsCmd.Flags().BoolP("timestamps", "t", false, "Print a timestamp on every line")
+	logsCmd.Flags().StringP("container", "c", "", "Print the logs of the sidecar with this name")
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"log"
+	"time"
 
 	"github.com/d-bolshakov/orchestrator/manager"
 	"github.com/spf13/cobra"
@@ -17,23 +18,45 @@ The manager controls the orchestration system and is responsible for:
  - Accepting tasks from users
  - Scheduling tasks onto worker nodes
  - Rescheduling tasks in the event of a node failure
- - Periodically polling workers to get task updates`,
+ - Periodically polling workers to get task updates
+ - Keeping the desired number of service replicas running
+ - Running jobs to completion and retrying failed job tasks
+ - Starting jobs on the schedule of cron jobs
+ - Running workflow steps once their dependencies complete
+ - Periodically moving tasks off overloaded workers`,
 	Run: func(cmd *cobra.Command, args []string) {
 		host, _ := cmd.Flags().GetString("host")
 		port, _ := cmd.Flags().GetInt("port")
 		workers, _ := cmd.Flags().GetStringSlice("workers")
 		schedulerType, _ := cmd.Flags().GetString("scheduler")
 		dbType, _ := cmd.Flags().GetString("dbtype")
+		rebalanceInterval, _ := cmd.Flags().GetDuration("rebalance-interval")
+		rebalanceThreshold, _ := cmd.Flags().GetInt("rebalance-threshold")
+		rebalanceSkew, _ := cmd.Flags().GetFloat64("rebalance-skew")
+		migrationTimeout, _ := cmd.Flags().GetDuration("migration-timeout")
+		maxMigrations, _ := cmd.Flags().GetInt("max-migrations")
 
 		log.Println("Starting manager.")
 		log.Printf("Workers: %v\n", workers)
 
 		m := manager.New(workers, schedulerType, dbType)
+		m.RebalanceInterval = rebalanceInterval
+		m.RebalanceThreshold = rebalanceThreshold
+		m.RebalanceSkew = rebalanceSkew
+		m.MigrationTimeout = migrationTimeout
+		m.MaxMigrations = maxMigrations
 		api := manager.Api{Address: host, Port: port, Manager: m}
 		go m.ProcessTasks()
 		go m.UpdateTasks()
 		go m.DoHeathChecks()
 		go m.CollectStats()
+		go m.ReconcileServices()
+		go m.ReconcileJobs()
+		go m.RunCronJobs()
+		go m.ReconcileWorkflows()
+		if rebalanceInterval > 0 {
+			go m.Rebalance()
+		}
 		log.Printf("Starting manager API on http://%s:%d", host, port)
 		api.Start()
 	},
@@ -47,4 +70,9 @@ func init() {
 	managerCmd.Flags().StringSliceP("workers", "w", []string{"localhost:5556"}, "List of workers on which the manager will schedule tasks.")
 	managerCmd.Flags().StringP("scheduler", "s", "epvm", "Name of scheduler to use.")
 	managerCmd.Flags().StringP("dbtype", "d", "inmemory", "Type of datastore to use for events and tasks (\"inmemory\" or \"persistent\")")
+	managerCmd.Flags().Duration("rebalance-interval", 5*time.Minute, "How often to look for unbalanced workers (0 disables rebalancing)")
+	managerCmd.Flags().Int("rebalance-threshold", 1, "Maximum allowed difference in running tasks between the busiest and the idlest worker, used until workers report their stats")
+	managerCmd.Flags().Float64("rebalance-skew", 0.2, "Maximum allowed difference in utilization of the most used resource between the busiest and the idlest worker, from 0 to 1")
+	managerCmd.Flags().Duration("migration-timeout", 5*time.Minute, "How long a moved task keeps running while its copy on the new worker gets ready")
+	managerCmd.Flags().Int("max-migrations", 1, "Maximum number of tasks moved per rebalancing cycle")
 }
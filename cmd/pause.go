@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/d-bolshakov/orchestrator/client"
+	"github.com/spf13/cobra"
+)
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Pause a running task",
+	Long: `orchestrator pause command.
+
+The pause command freezes the container of a running task. The task keeps
+its memory and its place on the worker, but gets no CPU time until it is
+resumed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		err := client.NewManagerClient(manager).PauseTask(args[0])
+		if err != nil {
+			log.Fatalf("Error pausing the task: %v", err)
+		}
+
+		log.Printf("Task %v has been paused.", args[0])
+	},
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume a paused task",
+	Long: `orchestrator resume command.
+
+The resume command lets a paused task run again.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		err := client.NewManagerClient(manager).ResumeTask(args[0])
+		if err != nil {
+			log.Fatalf("Error resuming the task: %v", err)
+		}
+
+		log.Printf("Task %v has been resumed.", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(resumeCmd)
+
+	pauseCmd.Flags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	resumeCmd.Flags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/d-bolshakov/orchestrator/client"
 	"github.com/d-bolshakov/orchestrator/task"
@@ -18,10 +19,16 @@ var runCmd = &cobra.Command{
 	Short: "Run a new task",
 	Long: `orchestrator run command.
 	
-The run command starts a new task.`,
+The run command starts a new task. The task is not started before its
+NotBefore time, which can also be set with --delay. Tasks sharing a
+concurrency key are held in the queue while the limit of the key is
+reached.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		manager, _ := cmd.Flags().GetString("manager")
 		filename, _ := cmd.Flags().GetString("filename")
+		delay, _ := cmd.Flags().GetDuration("delay")
+		concurrencyKey, _ := cmd.Flags().GetString("concurrency-key")
+		concurrencyLimit, _ := cmd.Flags().GetInt("concurrency-limit")
 
 		fullFilePath, err := filepath.Abs(filename)
 		if err != nil {
@@ -46,6 +53,16 @@ The run command starts a new task.`,
 			log.Fatalf("Error unmarshalling task to run: %v", err)
 		}
 
+		if delay > 0 {
+			te.Task.NotBefore = time.Now().UTC().Add(delay)
+		}
+		if concurrencyKey != "" {
+			te.Task.ConcurrencyKey = concurrencyKey
+		}
+		if concurrencyLimit > 0 {
+			te.Task.ConcurrencyLimit = concurrencyLimit
+		}
+
 		client := client.New(manager, "manager")
 		_, err = client.SendTask(te)
 		if err != nil {
@@ -61,6 +78,9 @@ func init() {
 
 	runCmd.Flags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
 	runCmd.Flags().StringP("filename", "f", "task.json", "Task specification file")
+	runCmd.Flags().Duration("delay", 0, "Do not start the task before this much time has passed")
+	runCmd.Flags().String("concurrency-key", "", "Key shared by tasks that may only run a limited number at a time")
+	runCmd.Flags().Int("concurrency-limit", 0, "Number of tasks with the concurrency key that may run at the same time (default 1)")
 }
 
 func fileExists(filename string) bool {
@@ -68,3 +88,25 @@ func fileExists(filename string) bool {
 
 	return !errors.Is(err, fs.ErrNotExist)
 }
+
+// readSpecFile reads a JSON specification file into v, exiting on any error.
+func readSpecFile(filename string, v any) {
+	fullFilePath, err := filepath.Abs(filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if !fileExists(fullFilePath) {
+		log.Fatalf("File %s does not exist.", filename)
+	}
+
+	data, err := os.ReadFile(fullFilePath)
+	if err != nil {
+		log.Fatalf("Unable to read file: %v", filename)
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		log.Fatalf("Error unmarshalling %s: %v", filename, err)
+	}
+}
@@ -0,0 +1,316 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/client"
+	"github.com/d-bolshakov/orchestrator/service"
+	"github.com/spf13/cobra"
+)
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Manage replicated services",
+	Long: `orchestrator service command.
+
+A service keeps the requested number of replicas of a task template running.
+The manager starts new tasks when replicas fail or disappear and stops tasks
+when the service is scaled down.`,
+}
+
+var serviceCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a service from a specification file",
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		filename, _ := cmd.Flags().GetString("filename")
+
+		author, _ := cmd.Flags().GetString("author")
+		changeCause, _ := cmd.Flags().GetString("change-cause")
+
+		var s service.Service
+		readSpecFile(filename, &s)
+		if cmd.Flags().Changed("author") || s.Author == "" {
+			s.Author = author
+		}
+		if changeCause != "" {
+			s.ChangeCause = changeCause
+		}
+
+		client := client.NewManagerClient(manager)
+		err := client.CreateService(s)
+		if err != nil {
+			log.Fatalf("Error creating service: %v", err)
+		}
+
+		log.Printf("Service %s has been created.", s.Name)
+	},
+}
+
+var serviceUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update a service from a specification file",
+	Long: `orchestrator service update command.
+
+Changing the task template starts a rolling update: tasks are replaced in
+batches, each batch has to pass its health checks before the next one is
+started, and the update is rolled back automatically once the configured
+number of new tasks fail.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		filename, _ := cmd.Flags().GetString("filename")
+
+		author, _ := cmd.Flags().GetString("author")
+		changeCause, _ := cmd.Flags().GetString("change-cause")
+
+		var s service.Service
+		readSpecFile(filename, &s)
+		if cmd.Flags().Changed("author") || s.Author == "" {
+			s.Author = author
+		}
+		if changeCause != "" {
+			s.ChangeCause = changeCause
+		}
+
+		client := client.NewManagerClient(manager)
+		err := client.UpdateService(s)
+		if err != nil {
+			log.Fatalf("Error updating service: %v", err)
+		}
+
+		log.Printf("Service %s has been updated.", s.Name)
+	},
+}
+
+var serviceScaleCmd = &cobra.Command{
+	Use:   "scale <name> <replicas>",
+	Short: "Change the number of replicas of a service",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		replicas, err := strconv.Atoi(args[1])
+		if err != nil {
+			log.Fatalf("Invalid number of replicas %s: %v", args[1], err)
+		}
+
+		client := client.NewManagerClient(manager)
+		err = client.ScaleService(args[0], replicas)
+		if err != nil {
+			log.Fatalf("Error scaling service: %v", err)
+		}
+
+		log.Printf("Service %s has been scaled to %d replicas.", args[0], replicas)
+	},
+}
+
+var serviceListCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List services",
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		services, err := client.GetServices()
+		if err != nil {
+			log.Fatalf("Error retrieving the list of services from the manager: %v", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "NAME\tIMAGE\tREPLICAS\tREVISION\tUPDATE\t")
+		for _, s := range services {
+			update := s.UpdateStatus.State
+			if update == "" {
+				update = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d/%d\t%d\t%s\t\n", s.Name, s.Template.Image, s.ReadyReplicas, s.Replicas, s.Revision, update)
+		}
+		w.Flush()
+	},
+}
+
+var serviceHistoryCmd = &cobra.Command{
+	Use:   "history <name>",
+	Short: "Show the revision history of a service",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		s, err := client.GetService(args[0])
+		if err != nil {
+			log.Fatalf("Error retrieving service: %v", err)
+		}
+		history, err := client.GetServiceHistory(args[0])
+		if err != nil {
+			log.Fatalf("Error retrieving service history: %v", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "REVISION\tCREATED\tAUTHOR\tIMAGE\tCHANGE CAUSE\t")
+		for _, r := range history {
+			revision := strconv.Itoa(r.Number)
+			if r.Number == s.Revision {
+				revision += " (current)"
+			}
+			created := r.Timestamp.Local().Format(time.DateTime)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", revision, created, r.Author, r.Template.Image, r.ChangeCause)
+		}
+		w.Flush()
+	},
+}
+
+var serviceRollbackCmd = &cobra.Command{
+	Use:   "rollback <name>",
+	Short: "Roll a service back to an earlier revision",
+	Long: `orchestrator service rollback command.
+
+Rolls the service back to the given revision, or to the previous one if no
+revision is given. The rollback is recorded as a new revision.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		revision, _ := cmd.Flags().GetInt("to-revision")
+		author, _ := cmd.Flags().GetString("author")
+
+		client := client.NewManagerClient(manager)
+		err := client.RollbackService(args[0], revision, author)
+		if err != nil {
+			log.Fatalf("Error rolling back service: %v", err)
+		}
+
+		log.Printf("Service %s is being rolled back.", args[0])
+	},
+}
+
+var servicePromoteCmd = &cobra.Command{
+	Use:   "promote <name>",
+	Short: "Promote a canary or blue/green update",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		err := client.PromoteService(args[0])
+		if err != nil {
+			log.Fatalf("Error promoting service: %v", err)
+		}
+
+		log.Printf("Service %s has been promoted.", args[0])
+	},
+}
+
+var serviceAbortCmd = &cobra.Command{
+	Use:   "abort <name>",
+	Short: "Abort an update and roll back to the previous revision",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		err := client.AbortService(args[0])
+		if err != nil {
+			log.Fatalf("Error aborting service update: %v", err)
+		}
+
+		log.Printf("Update of service %s has been aborted.", args[0])
+	},
+}
+
+var serviceStatusCmd = &cobra.Command{
+	Use:   "status <name>",
+	Short: "Show the update status and tasks of a service",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		s, err := client.GetService(args[0])
+		if err != nil {
+			log.Fatalf("Error retrieving service: %v", err)
+		}
+		tasks, err := client.GetTasks()
+		if err != nil {
+			log.Fatalf("Error retrieving the task list from the manager: %v", err)
+		}
+
+		cfg := s.UpdateConfig.WithDefaults()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Name:\t%s\n", s.Name)
+		fmt.Fprintf(w, "Image:\t%s\n", s.Template.Image)
+		fmt.Fprintf(w, "Replicas:\t%d/%d\n", s.ReadyReplicas, s.Replicas)
+		fmt.Fprintf(w, "Strategy:\t%s\n", cfg.Strategy)
+		fmt.Fprintf(w, "Revision:\t%d (active %d, previous %d)\n", s.Revision, s.ActiveRevision, s.PreviousRevision)
+		if s.UpdateStatus.State != "" {
+			fmt.Fprintf(w, "Update:\t%s, started %s\n", s.UpdateStatus.State, s.UpdateStatus.StartedAt.Local().Format(time.DateTime))
+			fmt.Fprintf(w, "Failures:\t%d/%d\n", s.UpdateStatus.Failures, cfg.FailureThreshold)
+		}
+		if s.UpdateStatus.Checks > 0 {
+			fmt.Fprintf(w, "Canary checks:\t%d/%d passed\n", s.UpdateStatus.ChecksPassed, s.UpdateStatus.Checks)
+		}
+		if s.UpdateStatus.Message != "" {
+			fmt.Fprintf(w, "Message:\t%s\n", s.UpdateStatus.Message)
+		}
+		w.Flush()
+
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "ID\tNAME\tREVISION\tSTATE\tIMAGE\t")
+		for _, t := range tasks {
+			if t.Service != s.Name || t.StopRequested {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t\n", t.ID, t.Name, t.Revision, t.State, t.Image)
+		}
+		w.Flush()
+	},
+}
+
+var serviceRemoveCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Short: "Remove a service and stop its tasks",
+	Long: `orchestrator service rm command.
+
+The manager refuses to remove the service if stopping its tasks would violate
+a disruption budget, unless --force is given.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		force, _ := cmd.Flags().GetBool("force")
+
+		client := client.NewManagerClient(manager)
+		var err error
+		if force {
+			err = client.ForceDeleteService(args[0])
+		} else {
+			err = client.DeleteService(args[0])
+		}
+		if err != nil {
+			log.Fatalf("Error removing service: %v", err)
+		}
+
+		log.Printf("Service %s has been removed.", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serviceCmd)
+	serviceCmd.AddCommand(serviceCreateCmd, serviceUpdateCmd, serviceScaleCmd, serviceListCmd, serviceHistoryCmd, serviceRollbackCmd, servicePromoteCmd, serviceAbortCmd, serviceStatusCmd, serviceRemoveCmd)
+
+	serviceCmd.PersistentFlags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	serviceCreateCmd.Flags().StringP("filename", "f", "service.json", "Service specification file")
+	serviceUpdateCmd.Flags().StringP("filename", "f", "service.json", "Service specification file")
+	serviceRemoveCmd.Flags().Bool("force", false, "Remove the service even if stopping its tasks violates a disruption budget")
+	serviceRollbackCmd.Flags().Int("to-revision", 0, "Revision to roll back to (defaults to the previous one)")
+	for _, c := range []*cobra.Command{serviceCreateCmd, serviceUpdateCmd, serviceRollbackCmd} {
+		c.Flags().String("author", os.Getenv("USER"), "Author of the change, recorded in the revision history")
+	}
+	for _, c := range []*cobra.Command{serviceCreateCmd, serviceUpdateCmd} {
+		c.Flags().String("change-cause", "", "Reason for the change, recorded in the revision history")
+	}
+}
@@ -28,7 +28,7 @@ The status command allows a user to get the status of tasks from the manager.`,
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
-		fmt.Fprintln(w, "ID\tNAME\tCREATED\tSTATE\tCONTAINERNAME\tIMAGE\t")
+		fmt.Fprintln(w, "ID\tNAME\tCREATED\tSTATE\tREADY\tRESTARTS\tCONTAINERNAME\tIMAGE\t")
 		for _, task := range tasks {
 			var start string
 			if task.StartTime.IsZero() {
@@ -38,8 +38,11 @@ The status command allows a user to get the status of tasks from the manager.`,
 			}
 
 			state := task.State.String()
+			if wait := time.Until(task.NextRetryTime); wait > 0 {
+				state = fmt.Sprintf("%s (retry in %s)", state, units.HumanDuration(wait))
+			}
 
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", task.ID, task.Name, start, state, task.Name, task.Image)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%d\t%s\t%s\t\n", task.ID, task.Name, start, state, task.Ready, task.RestartCount, task.Name, task.Image)
 		}
 		w.Flush()
 	},
@@ -12,13 +12,20 @@ var stopCmd = &cobra.Command{
 	Short: "Stop a running task",
 	Long: `orchestrator stop command.
 	
-The stop command stops a running task.`,
+The stop command stops a running task. The manager refuses to stop a task
+if that would violate a disruption budget, unless --force is given.`,
 	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		manager, _ := cmd.Flags().GetString("manager")
+		force, _ := cmd.Flags().GetBool("force")
 
-		client := client.New(manager, "manager")
-		err := client.StopTask(args[0])
+		client := client.NewManagerClient(manager)
+		var err error
+		if force {
+			err = client.ForceStopTask(args[0])
+		} else {
+			err = client.StopTask(args[0])
+		}
 		if err != nil {
 			log.Fatalf("Error sending request for stopping the task: %v", err)
 		}
@@ -31,4 +38,5 @@ func init() {
 	rootCmd.AddCommand(stopCmd)
 
 	stopCmd.Flags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	stopCmd.Flags().Bool("force", false, "Stop the task even if it violates a disruption budget")
 }
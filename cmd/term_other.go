@@ -0,0 +1,24 @@
+//go:build !linux
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+)
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func terminalSize(fd int) (uint, uint, error) {
+	return 0, 0, fmt.Errorf("terminals are only supported on Linux")
+}
+
+func makeRaw(fd int) (func(), error) {
+	return nil, fmt.Errorf("terminals are only supported on Linux")
+}
+
+func notifyResize() chan os.Signal {
+	return make(chan os.Signal)
+}
@@ -6,7 +6,9 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/d-bolshakov/orchestrator/logsink"
 	"github.com/d-bolshakov/orchestrator/worker"
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
@@ -22,14 +24,38 @@ var workerCmd = &cobra.Command{
 		port, _ := cmd.Flags().GetInt("port")
 		name, _ := cmd.Flags().GetString("name")
 		dbType, _ := cmd.Flags().GetString("dbtype")
+		logDir, _ := cmd.Flags().GetString("log-dir")
+		logMaxSize, _ := cmd.Flags().GetInt64("log-max-size")
+		logMaxFiles, _ := cmd.Flags().GetInt("log-max-files")
+		logRetention, _ := cmd.Flags().GetDuration("log-retention")
+		logSinks, _ := cmd.Flags().GetStringSlice("log-sink")
+		logSinkBuffer, _ := cmd.Flags().GetInt("log-sink-buffer")
 
 		log.Println("Starting worker.")
 
 		w := worker.New(name, dbType)
+		if logDir != "" {
+			w.LogDir = logDir
+		}
+		w.LogMaxSize = logMaxSize
+		w.LogMaxFiles = logMaxFiles
+		w.LogRetention = logRetention
+		if len(logSinks) > 0 {
+			sinks := []logsink.Sink{}
+			for _, spec := range logSinks {
+				sink, err := logsink.Parse(spec)
+				if err != nil {
+					log.Fatalf("Error setting up log sink %s: %v", spec, err)
+				}
+				sinks = append(sinks, sink)
+			}
+			w.Shipper = logsink.NewShipper(sinks, logSinkBuffer)
+		}
 		api := worker.Api{Address: host, Port: port, Worker: w}
 		go w.RunTasks()
 		go w.CollectStats()
 		go w.UpdateTasks()
+		go w.ProbeTasks()
 		log.Printf("Starting worker API on http://%s:%d", host, port)
 		api.Start()
 
@@ -43,4 +69,10 @@ func init() {
 	workerCmd.Flags().IntP("port", "p", 5556, "Port on which to listen")
 	workerCmd.Flags().StringP("name", "n", fmt.Sprintf("worker-%s", uuid.New().String()), "Name of the worker")
 	workerCmd.Flags().StringP("dbtype", "d", "inmemory", "Type of datastore to use for tasks (\"inmemory\" or \"persistent\")")
+	workerCmd.Flags().String("log-dir", "logs", "Directory in which the logs of tasks are kept, relative to the working directory like the persistent task store")
+	workerCmd.Flags().Int64("log-max-size", 10*1024*1024, "Size in bytes at which a log file is rotated")
+	workerCmd.Flags().Int("log-max-files", 5, "Number of rotated log files kept per container")
+	workerCmd.Flags().Duration("log-retention", 24*time.Hour, "How long the logs of a stopped task are kept")
+	workerCmd.Flags().StringSlice("log-sink", nil, "Sink to forward the logs of tasks to, repeatable: \"syslog[:tag]\", \"file:<path>\" for JSON lines, an http(s) URL to push JSON lines to, or \"loki+<url>\" for Loki")
+	workerCmd.Flags().Int("log-sink-buffer", 10000, "Number of log lines buffered per sink, lines are dropped once a sink falls this far behind")
 }
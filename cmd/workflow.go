@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/d-bolshakov/orchestrator/client"
+	"github.com/d-bolshakov/orchestrator/workflow"
+	"github.com/spf13/cobra"
+)
+
+var workflowCmd = &cobra.Command{
+	Use:   "workflow",
+	Short: "Manage workflows",
+	Long: `orchestrator workflow command.
+
+A workflow is a graph of steps, each running a task to completion. A step
+starts once the steps it depends on have finished and their conditions
+(success, failure or always) hold, and is skipped otherwise. Outputs printed
+by a step as "::set-output name=value" are passed to the steps depending on
+it as OUTPUT_<STEP>_<NAME> environment variables.`,
+}
+
+var workflowSubmitCmd = &cobra.Command{
+	Use:   "submit",
+	Short: "Submit a workflow from a specification file",
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+		filename, _ := cmd.Flags().GetString("filename")
+
+		var w workflow.Workflow
+		readSpecFile(filename, &w)
+
+		client := client.NewManagerClient(manager)
+		err := client.CreateWorkflow(w)
+		if err != nil {
+			log.Fatalf("Error submitting workflow: %v", err)
+		}
+
+		log.Printf("Workflow %s has been submitted.", w.Name)
+	},
+}
+
+var workflowListCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List workflows",
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		workflows, err := client.GetWorkflows()
+		if err != nil {
+			log.Fatalf("Error retrieving the list of workflows from the manager: %v", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "NAME\tSTEPS\tSTATE\tSTARTED\t")
+		for _, wf := range workflows {
+			finished := 0
+			for _, s := range wf.Steps {
+				if s.Finished() {
+					finished++
+				}
+			}
+			fmt.Fprintf(w, "%s\t%d/%d\t%s\t%s\t\n", wf.Name, finished, len(wf.Steps), wf.State, formatTime(wf.StartTime))
+		}
+		w.Flush()
+	},
+}
+
+var workflowStatusCmd = &cobra.Command{
+	Use:   "status <name>",
+	Short: "Show the state of the steps of a workflow",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		wf, err := client.GetWorkflow(args[0])
+		if err != nil {
+			log.Fatalf("Error retrieving workflow: %v", err)
+		}
+
+		fmt.Printf("Workflow %s is %s", wf.Name, wf.State)
+		if wf.Message != "" {
+			fmt.Printf(": %s", wf.Message)
+		}
+		fmt.Println()
+		fmt.Println()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "STEP\tSTATE\tDEPENDS ON\tTASK\tMESSAGE\t")
+		for _, s := range wf.Steps {
+			deps := []string{}
+			for _, d := range s.DependsOn {
+				condition := d.Condition
+				if condition == "" {
+					condition = workflow.OnSuccess
+				}
+				deps = append(deps, fmt.Sprintf("%s (%s)", d.Step, condition))
+			}
+
+			taskID := "-"
+			if s.State != workflow.StepWaiting && s.State != workflow.StepSkipped {
+				taskID = s.TaskID.String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", s.Name, s.State, strings.Join(deps, ", "), taskID, s.Message)
+		}
+		w.Flush()
+	},
+}
+
+var workflowRemoveCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Short: "Remove a workflow and stop its running steps",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manager, _ := cmd.Flags().GetString("manager")
+
+		client := client.NewManagerClient(manager)
+		err := client.DeleteWorkflow(args[0])
+		if err != nil {
+			log.Fatalf("Error removing workflow: %v", err)
+		}
+
+		log.Printf("Workflow %s has been removed.", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(workflowCmd)
+	workflowCmd.AddCommand(workflowSubmitCmd, workflowListCmd, workflowStatusCmd, workflowRemoveCmd)
+
+	workflowCmd.PersistentFlags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	workflowSubmitCmd.Flags().StringP("filename", "f", "workflow.json", "Workflow specification file")
+}
@@ -0,0 +1,110 @@
+package job
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	ConcurrencyAllow   = "Allow"
+	ConcurrencyForbid  = "Forbid"
+	ConcurrencyReplace = "Replace"
+)
+
+// CronJob creates a job from its template every time the schedule fires.
+//
+// ConcurrencyPolicy decides what happens when the previous job is still
+// running: Allow starts another one, Forbid skips the run and Replace stops
+// the running job first. A run that couldn't be started within
+// StartingDeadlineSeconds of its scheduled time is skipped, 0 means no
+// deadline. Only the latest SuccessfulJobsHistoryLimit succeeded and
+// FailedJobsHistoryLimit failed jobs are kept.
+type CronJob struct {
+	Name                       string
+	Schedule                   string
+	TimeZone                   string
+	ConcurrencyPolicy          string
+	StartingDeadlineSeconds    int
+	SuccessfulJobsHistoryLimit *int
+	FailedJobsHistoryLimit     *int
+	Template                   Job
+	Created                    time.Time
+	LastScheduleTime           time.Time
+	NextScheduleTime           time.Time
+	Message                    string
+}
+
+func (c *CronJob) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("cron job must have a name")
+	}
+
+	_, err := ParseSchedule(c.Schedule)
+	if err != nil {
+		return fmt.Errorf("cron job %s has an invalid schedule: %v", c.Name, err)
+	}
+
+	_, err = c.Location()
+	if err != nil {
+		return fmt.Errorf("cron job %s has an invalid time zone: %v", c.Name, err)
+	}
+
+	switch c.ConcurrencyPolicy {
+	case "", ConcurrencyAllow, ConcurrencyForbid, ConcurrencyReplace:
+	default:
+		return fmt.Errorf("cron job %s has unknown concurrency policy %s", c.Name, c.ConcurrencyPolicy)
+	}
+
+	if c.StartingDeadlineSeconds < 0 {
+		return fmt.Errorf("cron job %s cannot have a negative starting deadline", c.Name)
+	}
+
+	if (c.SuccessfulJobsHistoryLimit != nil && *c.SuccessfulJobsHistoryLimit < 0) ||
+		(c.FailedJobsHistoryLimit != nil && *c.FailedJobsHistoryLimit < 0) {
+		return fmt.Errorf("cron job %s cannot have a negative history limit", c.Name)
+	}
+
+	if c.Template.Template.Image == "" {
+		return fmt.Errorf("cron job %s must have an image in its task template", c.Name)
+	}
+
+	j := c.NewJob(time.Now())
+	return j.Validate()
+}
+
+// Location returns the time zone the schedule is evaluated in, UTC unless
+// the cron job says otherwise.
+func (c *CronJob) Location() (*time.Location, error) {
+	if c.TimeZone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(c.TimeZone)
+}
+
+// WithDefaults returns a copy of the cron job with zero values replaced by
+// the defaults: concurrent runs are allowed and the history keeps 3
+// succeeded and 1 failed job.
+func (c CronJob) WithDefaults() CronJob {
+	if c.ConcurrencyPolicy == "" {
+		c.ConcurrencyPolicy = ConcurrencyAllow
+	}
+	if c.SuccessfulJobsHistoryLimit == nil {
+		limit := 3
+		c.SuccessfulJobsHistoryLimit = &limit
+	}
+	if c.FailedJobsHistoryLimit == nil {
+		limit := 1
+		c.FailedJobsHistoryLimit = &limit
+	}
+
+	return c
+}
+
+// NewJob returns the job to run for the given scheduled time.
+func (c *CronJob) NewJob(scheduled time.Time) *Job {
+	j := c.Template
+	j.Name = fmt.Sprintf("%s-%d", c.Name, scheduled.Unix())
+	j.CronJob = c.Name
+
+	return &j
+}
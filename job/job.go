@@ -0,0 +1,176 @@
+package job
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/task"
+)
+
+const (
+	Running   = "running"
+	Succeeded = "succeeded"
+	Failed    = "failed"
+)
+
+// Job runs tasks from a template until Completions of them have exited
+// successfully, with at most Parallelism tasks running at the same time.
+// Failed tasks are retried until more than BackoffLimit of them have failed,
+// waiting BackoffSeconds after the first failure and twice as long after each
+// following one, up to MaxBackoffSeconds. CronJob is the name of the cron
+// job that created the job, if any.
+//
+// A job with Count or Parameters is indexed: it runs one task per index
+// instead, until the task of every index has succeeded. Each task gets its
+// index in the JOB_INDEX environment variable and, with Parameters, the
+// parameter set of its index as further environment variables.
+type Job struct {
+	Name              string
+	CronJob           string
+	Template          task.Task
+	Count             int
+	Parameters        []map[string]string
+	Completions       int
+	Parallelism       int
+	BackoffLimit      *int
+	BackoffSeconds    int
+	MaxBackoffSeconds int
+	Status            Status
+}
+
+type Status struct {
+	State            string
+	Active           int
+	Succeeded        int
+	Failed           int
+	SucceededIndexes string
+	FailedIndexes    string
+	StartTime        time.Time
+	CompletionTime   time.Time
+	NextRetryTime    time.Time
+	Message          string
+}
+
+func (j *Job) Validate() error {
+	if j.Name == "" {
+		return fmt.Errorf("job must have a name")
+	}
+	if j.Template.Image == "" {
+		return fmt.Errorf("job %s must have an image in its task template", j.Name)
+	}
+	// The template is validated as the job task it becomes, so that restart
+	// policies jobs don't allow are rejected.
+	t := j.Template
+	t.Kind = task.KindJob
+	err := t.Validate()
+	if err != nil {
+		return fmt.Errorf("job %s: %v", j.Name, err)
+	}
+	if j.Count < 0 || j.Completions < 0 || j.Parallelism < 0 || (j.BackoffLimit != nil && *j.BackoffLimit < 0) || j.BackoffSeconds < 0 || j.MaxBackoffSeconds < 0 {
+		return fmt.Errorf("job %s cannot have negative values", j.Name)
+	}
+	if j.Count > 0 && len(j.Parameters) > 0 && j.Count != len(j.Parameters) {
+		return fmt.Errorf("job %s has a count of %d but %d parameter sets", j.Name, j.Count, len(j.Parameters))
+	}
+	if j.Indexed() && j.Completions != 0 && j.Completions != j.Indexes() {
+		return fmt.Errorf("job %s has %d indexes and cannot require %d completions", j.Name, j.Indexes(), j.Completions)
+	}
+
+	return nil
+}
+
+// Indexed reports whether the job runs one task per index.
+func (j Job) Indexed() bool {
+	return j.Count > 0 || len(j.Parameters) > 0
+}
+
+// Indexes returns the number of indexes of an indexed job.
+func (j Job) Indexes() int {
+	return max(j.Count, len(j.Parameters))
+}
+
+// Env returns the environment variables that identify the given index to
+// its task.
+func (j Job) Env(index int) []string {
+	env := []string{fmt.Sprintf("JOB_INDEX=%d", index)}
+	if index >= len(j.Parameters) {
+		return env
+	}
+
+	names := []string{}
+	for name := range j.Parameters[index] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, fmt.Sprintf("%s=%s", name, j.Parameters[index][name]))
+	}
+
+	return env
+}
+
+// WithDefaults returns a copy of the job with zero values replaced by the
+// defaults: a single completion, or one per index for indexed jobs, one task
+// at a time and up to 6 retries starting with a 10 second backoff capped at
+// 6 minutes.
+func (j Job) WithDefaults() Job {
+	if j.Indexed() {
+		j.Completions = j.Indexes()
+	}
+	if j.Completions == 0 {
+		j.Completions = 1
+	}
+	if j.Parallelism == 0 {
+		j.Parallelism = 1
+	}
+	if j.BackoffLimit == nil {
+		backoffLimit := 6
+		j.BackoffLimit = &backoffLimit
+	}
+	if j.BackoffSeconds == 0 {
+		j.BackoffSeconds = 10
+	}
+	if j.MaxBackoffSeconds == 0 {
+		j.MaxBackoffSeconds = 360
+	}
+
+	return j
+}
+
+// Backoff returns how long to wait before starting a new task after the
+// given number of failures.
+func (j Job) Backoff(failures int) time.Duration {
+	if failures == 0 {
+		return 0
+	}
+
+	return task.ExponentialBackoff(j.BackoffSeconds, j.MaxBackoffSeconds, failures-1)
+}
+
+func (j *Job) Finished() bool {
+	return j.Status.State == Succeeded || j.Status.State == Failed
+}
+
+// FormatIndexes lists sorted indexes compactly, collapsing runs of
+// consecutive indexes into ranges such as "0-3,7,9-10".
+func FormatIndexes(indexes []int) string {
+	parts := []string{}
+	for i := 0; i < len(indexes); {
+		j := i
+		for j+1 < len(indexes) && indexes[j+1] == indexes[j]+1 {
+			j++
+		}
+
+		if j == i {
+			parts = append(parts, strconv.Itoa(indexes[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", indexes[i], indexes[j]))
+		}
+		i = j + 1
+	}
+
+	return strings.Join(parts, ",")
+}
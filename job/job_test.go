@@ -0,0 +1,80 @@
+package job
+
+import (
+	"testing"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/task"
+)
+
+func TestJobBackoff(t *testing.T) {
+	j := Job{BackoffSeconds: 10, MaxBackoffSeconds: 60}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, 60 * time.Second},
+		{10, 60 * time.Second},
+	}
+
+	for _, tt := range tests {
+		got := j.Backoff(tt.failures)
+		if got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestFormatIndexes(t *testing.T) {
+	tests := []struct {
+		indexes []int
+		want    string
+	}{
+		{nil, ""},
+		{[]int{4}, "4"},
+		{[]int{0, 1, 2, 3}, "0-3"},
+		{[]int{1, 3, 5}, "1,3,5"},
+		{[]int{0, 1, 2, 3, 7, 9, 10}, "0-3,7,9-10"},
+		{[]int{0, 2, 3}, "0,2-3"},
+	}
+
+	for _, tt := range tests {
+		got := FormatIndexes(tt.indexes)
+		if got != tt.want {
+			t.Errorf("FormatIndexes(%v) = %q, want %q", tt.indexes, got, tt.want)
+		}
+	}
+}
+
+func TestJobValidate(t *testing.T) {
+	template := task.Task{Image: "busybox"}
+	always := template
+	always.RestartPolicy = task.RestartPolicy{Mode: task.RestartAlways}
+	onFailure := template
+	onFailure.RestartPolicy = task.RestartPolicy{Mode: task.RestartOnFailure}
+
+	tests := []struct {
+		name    string
+		job     Job
+		wantErr bool
+	}{
+		{"plain", Job{Name: "j", Template: template}, false},
+		{"restart on failure", Job{Name: "j", Template: onFailure}, false},
+		{"restart always", Job{Name: "j", Template: always}, true},
+		{"no image", Job{Name: "j"}, true},
+		{"negative parallelism", Job{Name: "j", Template: template, Parallelism: -1}, true},
+		{"count and parameters differ", Job{Name: "j", Template: template, Count: 2, Parameters: []map[string]string{{"a": "1"}}}, true},
+		{"completions differ from indexes", Job{Name: "j", Template: template, Count: 2, Completions: 3}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.job.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package job
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// A Monday.
+	monday := time.Date(2024, time.January, 15, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 * * * *", monday, time.Date(2024, time.January, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 */6 * * *", monday, time.Date(2024, time.January, 15, 12, 0, 0, 0, time.UTC)},
+		{"30 9-17 * * mon-fri", monday, time.Date(2024, time.January, 15, 11, 30, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", monday, time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jun *", monday, time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", monday, time.Date(2024, time.January, 21, 12, 0, 0, 0, time.UTC)},
+		// Both day fields are restricted, either of them matches.
+		{"0 0 13 * fri", monday, time.Date(2024, time.January, 19, 0, 0, 0, 0, time.UTC)},
+		// A stepped * leaves the day of month unrestricted, both have to match.
+		{"0 0 */2 * mon", monday, time.Date(2024, time.January, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * mon", monday, time.Date(2024, time.January, 22, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2024, time.January, 31, 10, 30, 0, 0, time.UTC), time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", monday, time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2024, time.December, 31, 23, 59, 0, 0, time.UTC), time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", monday, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q) failed: %v", tt.expr, err)
+			continue
+		}
+
+		got := s.Next(tt.from)
+		if !got.Equal(tt.want) {
+			t.Errorf("%q: Next(%v) = %v, want %v", tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+	} {
+		_, err := ParseSchedule(expr)
+		if err == nil {
+			t.Errorf("ParseSchedule(%q) succeeded, want an error", expr)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package logsink
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Source tells where a log line comes from.
+type Source struct {
+	TaskID    string            `json:"task_id"`
+	TaskName  string            `json:"task_name"`
+	Container string            `json:"container"`
+	Node      string            `json:"node"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// Entry is a line logged by a container of a task.
+type Entry struct {
+	Source
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"`
+	Line   string    `json:"line"`
+}
+
+// Sink is a destination for log entries. Write is given batches of entries
+// and is never called concurrently.
+type Sink interface {
+	Name() string
+	Write(entries []Entry) error
+}
+
+const (
+	// MaxBatch is the largest number of entries written to a sink at once.
+	MaxBatch = 500
+	// FlushInterval is how long entries wait for a batch to fill up.
+	FlushInterval = time.Second
+	// MaxAttempts is the number of times a batch is written to a failing
+	// sink before it is dropped.
+	MaxAttempts = 3
+)
+
+// Shipper forwards log entries to sinks. Every sink has a buffer of its own,
+// and entries that don't fit in it are dropped, so that a slow or failing
+// sink holds up neither the containers nor the other sinks.
+type Shipper struct {
+	queues []*queue
+}
+
+type queue struct {
+	sink    Sink
+	entries chan Entry
+	dropped atomic.Int64
+}
+
+func NewShipper(sinks []Sink, bufferSize int) *Shipper {
+	s := &Shipper{}
+	for _, sink := range sinks {
+		q := &queue{
+			sink:    sink,
+			entries: make(chan Entry, bufferSize),
+		}
+		s.queues = append(s.queues, q)
+		go q.run()
+	}
+
+	return s
+}
+
+// Ship hands an entry to every sink without waiting.
+func (s *Shipper) Ship(e Entry) {
+	for _, q := range s.queues {
+		select {
+		case q.entries <- e:
+		default:
+			q.drop(1)
+		}
+	}
+}
+
+// drop counts entries the sink lost, reported with its next batch.
+func (q *queue) drop(n int) {
+	q.dropped.Add(int64(n))
+}
+
+func (q *queue) run() {
+	ticker := time.NewTicker(FlushInterval)
+	defer ticker.Stop()
+
+	batch := []Entry{}
+	for {
+		select {
+		case e := <-q.entries:
+			batch = append(batch, e)
+			if len(batch) < MaxBatch {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		q.write(batch)
+		batch = []Entry{}
+	}
+}
+
+func (q *queue) write(batch []Entry) {
+	if n := q.dropped.Swap(0); n > 0 {
+		log.Printf("Log sink %s fell behind, dropped %d entries\n", q.sink.Name(), n)
+	}
+
+	var err error
+	for attempt := 1; attempt <= MaxAttempts; attempt++ {
+		err = q.sink.Write(batch)
+		if err == nil {
+			return
+		}
+
+		log.Printf("Error writing %d entries to log sink %s, attempt %d: %v\n", len(batch), q.sink.Name(), attempt, err)
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+
+	q.drop(len(batch))
+}
@@ -0,0 +1,145 @@
+package logsink
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// receive collects the batches pushed to a test server as JSON lines.
+func receive(t *testing.T, batches chan<- []Entry, r *http.Request) {
+	entries := []Entry{}
+	dec := json.NewDecoder(r.Body)
+	for {
+		var e Entry
+		err := dec.Decode(&e)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Errorf("Error decoding pushed entries: %v", err)
+			return
+		}
+		entries = append(entries, e)
+	}
+	batches <- entries
+}
+
+func waitForBatch(t *testing.T, batches <-chan []Entry) []Entry {
+	select {
+	case b := <-batches:
+		return b
+	case <-time.After(5 * time.Second):
+		t.Fatalf("No batch pushed within 5 seconds")
+		return nil
+	}
+}
+
+func testEntry(i int) Entry {
+	return Entry{
+		Source: Source{TaskID: "task", TaskName: "web", Container: "main", Node: "worker-1"},
+		Time:   time.Now().UTC(),
+		Stream: "stdout",
+		Line:   fmt.Sprintf("line %d", i),
+	}
+}
+
+func TestShipperBatches(t *testing.T) {
+	batches := make(chan []Entry, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receive(t, batches, r)
+	}))
+	defer srv.Close()
+
+	s := NewShipper([]Sink{NewHTTPSink(srv.URL, FormatJSONLines)}, 2*MaxBatch)
+	for i := 0; i < MaxBatch+10; i++ {
+		s.Ship(testEntry(i))
+	}
+
+	// A full batch goes out right away, the rest once FlushInterval passed.
+	next := 0
+	for _, want := range []int{MaxBatch, 10} {
+		b := waitForBatch(t, batches)
+		if len(b) != want {
+			t.Fatalf("Got a batch of %d entries, want %d", len(b), want)
+		}
+		for _, e := range b {
+			if e.Line != fmt.Sprintf("line %d", next) || e.TaskName != "web" || e.Node != "worker-1" {
+				t.Fatalf("Got entry %+v, want line %d of task web on worker-1", e, next)
+			}
+			next++
+		}
+	}
+}
+
+func TestShipperRetries(t *testing.T) {
+	batches := make(chan []Entry, 10)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		receive(t, batches, r)
+	}))
+	defer srv.Close()
+
+	s := NewShipper([]Sink{NewHTTPSink(srv.URL, FormatJSONLines)}, 10)
+	for i := 0; i < 3; i++ {
+		s.Ship(testEntry(i))
+	}
+
+	b := waitForBatch(t, batches)
+	if len(b) != 3 {
+		t.Errorf("Got a batch of %d entries, want 3", len(b))
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Sink got %d requests, want 2", n)
+	}
+	if n := s.queues[0].dropped.Load(); n != 0 {
+		t.Errorf("Dropped %d entries, want none", n)
+	}
+}
+
+func TestShipperDropsWhenBufferFull(t *testing.T) {
+	batches := make(chan []Entry, 10)
+	release := make(chan struct{})
+	var once sync.Once
+	unblock := func() { once.Do(func() { close(release) }) }
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receive(t, batches, r)
+		<-release
+	}))
+	defer srv.Close()
+	defer unblock()
+
+	s := NewShipper([]Sink{NewHTTPSink(srv.URL, FormatJSONLines)}, 2)
+	q := s.queues[0]
+
+	// While the sink is stuck on the first batch, only the buffer fills up.
+	s.Ship(testEntry(0))
+	waitForBatch(t, batches)
+	for i := 1; i <= 5; i++ {
+		s.Ship(testEntry(i))
+	}
+	if n := q.dropped.Load(); n != 3 {
+		t.Errorf("Dropped %d entries, want 3", n)
+	}
+
+	// The drops are reported, and the counter reset, with the next batch.
+	unblock()
+	b := waitForBatch(t, batches)
+	if len(b) != 2 || b[0].Line != "line 1" || b[1].Line != "line 2" {
+		t.Errorf("Got batch %+v, want lines 1 and 2", b)
+	}
+	if n := q.dropped.Load(); n != 0 {
+		t.Errorf("Dropped counter is %d after the next batch, want 0", n)
+	}
+}
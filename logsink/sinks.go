@@ -0,0 +1,252 @@
+package logsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse returns the sink described by spec, one of:
+//
+//	syslog[:tag]             the local syslog daemon, tag defaults to "orchestrator"
+//	file:<path>              a file with an entry per line, in JSON
+//	http[s]://<host>/<path>  entries pushed as JSON lines, as taken by Vector,
+//	                         Fluent Bit or Logstash HTTP inputs
+//	loki+http[s]://<host>    entries pushed to the Loki push API, at
+//	                         /loki/api/v1/push unless a path is given
+func Parse(spec string) (Sink, error) {
+	switch {
+	case spec == "syslog" || strings.HasPrefix(spec, "syslog:"):
+		tag := strings.TrimPrefix(strings.TrimPrefix(spec, "syslog"), ":")
+		if tag == "" {
+			tag = "orchestrator"
+		}
+		return NewSyslogSink(tag)
+	case strings.HasPrefix(spec, "file:"):
+		path := strings.TrimPrefix(spec, "file:")
+		if path == "" {
+			return nil, fmt.Errorf("file log sink needs a path")
+		}
+		return NewFileSink(path)
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		return NewHTTPSink(spec, FormatJSONLines), nil
+	case strings.HasPrefix(spec, "loki+http://") || strings.HasPrefix(spec, "loki+https://"):
+		u, err := url.Parse(strings.TrimPrefix(spec, "loki+"))
+		if err != nil {
+			return nil, err
+		}
+		if u.Path == "" || u.Path == "/" {
+			u.Path = "/loki/api/v1/push"
+		}
+		return NewHTTPSink(u.String(), FormatLoki), nil
+	}
+
+	return nil, fmt.Errorf("unknown log sink %q", spec)
+}
+
+// SyslogSink writes entries to the local syslog daemon, lines written to
+// stderr with the error priority.
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+func NewSyslogSink(tag string) (*SyslogSink, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_USER, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SyslogSink{writer: w}, nil
+}
+
+func (s *SyslogSink) Name() string {
+	return "syslog"
+}
+
+func (s *SyslogSink) Write(entries []Entry) error {
+	for _, e := range entries {
+		msg := fmt.Sprintf("task=%s name=%s container=%s node=%s %s", e.TaskID, e.TaskName, e.Container, e.Node, e.Line)
+
+		var err error
+		if e.Stream == "stderr" {
+			err = s.writer.Err(msg)
+		} else {
+			err = s.writer.Info(msg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FileSink appends entries to a file, one JSON object per line.
+type FileSink struct {
+	path string
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSink{path: path, file: f}, nil
+}
+
+func (s *FileSink) Name() string {
+	return "file:" + s.path
+}
+
+func (s *FileSink) Write(entries []Entry) error {
+	var buf bytes.Buffer
+	err := writeJSONLines(&buf, entries)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.file.Write(buf.Bytes())
+	return err
+}
+
+func writeJSONLines(w io.Writer, entries []Entry) error {
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		err := enc.Encode(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Formats of the body pushed by an HTTPSink.
+const (
+	FormatJSONLines = "jsonlines"
+	FormatLoki      = "loki"
+)
+
+// HTTPSink pushes batches of entries to an HTTP endpoint with POST requests.
+type HTTPSink struct {
+	URL    string
+	Format string
+	Client *http.Client
+}
+
+func NewHTTPSink(endpoint string, format string) *HTTPSink {
+	return &HTTPSink{
+		URL:    endpoint,
+		Format: format,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *HTTPSink) Name() string {
+	return s.URL
+}
+
+func (s *HTTPSink) Write(entries []Entry) error {
+	var buf bytes.Buffer
+	contentType := "application/x-ndjson"
+
+	var err error
+	switch s.Format {
+	case FormatLoki:
+		contentType = "application/json"
+		err = json.NewEncoder(&buf).Encode(lokiPush(entries))
+	default:
+		err = writeJSONLines(&buf, entries)
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.Client.Post(s.URL, contentType, &buf)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded with %s", s.URL, resp.Status)
+	}
+
+	return nil
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type lokiRequest struct {
+	Streams []*lokiStream `json:"streams"`
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// lokiPush groups entries into Loki streams by their source. Task labels
+// become stream labels, with the characters Loki doesn't allow in label
+// names replaced by underscores.
+func lokiPush(entries []Entry) lokiRequest {
+	streams := make(map[string]*lokiStream)
+	keys := []string{}
+	for _, e := range entries {
+		labels := map[string]string{
+			"task_id":   e.TaskID,
+			"task_name": e.TaskName,
+			"container": e.Container,
+			"node":      e.Node,
+			"stream":    e.Stream,
+		}
+		for k, v := range e.Labels {
+			name := invalidLabelChars.ReplaceAllString(k, "_")
+			if _, ok := labels[name]; !ok {
+				labels[name] = v
+			}
+		}
+
+		key := streamKey(labels)
+		s, ok := streams[key]
+		if !ok {
+			s = &lokiStream{Stream: labels}
+			streams[key] = s
+			keys = append(keys, key)
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(e.Time.UnixNano(), 10), e.Line})
+	}
+
+	req := lokiRequest{}
+	for _, key := range keys {
+		req.Streams = append(req.Streams, streams[key])
+	}
+	return req
+}
+
+func streamKey(labels map[string]string) string {
+	names := []string{}
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, k := range names {
+		fmt.Fprintf(&b, "%s=%q,", k, labels[k])
+	}
+	return b.String()
+}
@@ -17,13 +17,13 @@ func mainOld() {
 	mport, _ := strconv.Atoi(os.Getenv("ORCHESTRATOR_MANAGER_PORT"))
 
 	fmt.Println("Starting worker")
-	w1 := worker.New("inmemory")
+	w1 := worker.New("worker-1", "inmemory")
 	wapi1 := worker.Api{Address: whost, Port: wport, Worker: w1}
 
-	w2 := worker.New("inmemory")
+	w2 := worker.New("worker-2", "inmemory")
 	wapi2 := worker.Api{Address: whost, Port: wport + 1, Worker: w2}
 
-	w3 := worker.New("inmemory")
+	w3 := worker.New("worker-3", "inmemory")
 	wapi3 := worker.Api{Address: whost, Port: wport + 2, Worker: w3}
 
 	go w1.RunTasks()
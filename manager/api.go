@@ -16,16 +16,93 @@ type Api struct {
 
 func (a *Api) initRouter() {
 	a.Router = chi.NewRouter()
-	a.Router.Route("/tasks", func(r chi.Router) {
+
+	// Streams last for as long as the client keeps them open, so these
+	// handlers only lock the manager while they look up the task.
+	a.Router.Group(func(r chi.Router) {
+		r.Get("/tasks/{taskID}/logs", a.GetTaskLogsHandler)
+		r.Get("/tasks/{taskID}/archive", a.GetTaskArchiveHandler)
+		r.Put("/tasks/{taskID}/archive", a.PutTaskArchiveHandler)
+		r.Post("/tasks/{taskID}/exec", a.ExecTaskHandler)
+	})
+
+	a.Router.Group(func(r chi.Router) {
+		r.Use(a.lockManager)
+		a.initLockedRoutes(r)
+	})
+}
+
+// lockManager holds the manager lock for the whole request.
+func (a *Api) lockManager(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Manager.mu.Lock()
+		defer a.Manager.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Api) initLockedRoutes(router chi.Router) {
+	router.Route("/tasks", func(r chi.Router) {
 		r.Post("/", a.StartTaskHandler)
 		r.Get("/", a.GetTasksHandler)
 		r.Route("/{taskID}", func(r chi.Router) {
+			r.Get("/", a.GetTaskHandler)
 			r.Delete("/", a.StopTaskHandler)
+			r.Post("/pause", a.PauseTaskHandler)
+			r.Post("/resume", a.ResumeTaskHandler)
+			r.Route("/exec/{execID}", func(r chi.Router) {
+				r.Get("/", a.GetExecHandler)
+				r.Post("/resize", a.ResizeExecHandler)
+			})
 		})
 	})
-	a.Router.Route("/nodes", func(r chi.Router) {
+	router.Route("/nodes", func(r chi.Router) {
 		r.Get("/", a.GetNodesHandler)
 	})
+	router.Route("/budgets", func(r chi.Router) {
+		r.Post("/", a.CreateBudgetHandler)
+		r.Get("/", a.GetBudgetsHandler)
+		r.Route("/{name}", func(r chi.Router) {
+			r.Delete("/", a.DeleteBudgetHandler)
+		})
+	})
+	router.Route("/services", func(r chi.Router) {
+		r.Post("/", a.CreateServiceHandler)
+		r.Get("/", a.GetServicesHandler)
+		r.Route("/{name}", func(r chi.Router) {
+			r.Get("/", a.GetServiceHandler)
+			r.Put("/", a.UpdateServiceHandler)
+			r.Delete("/", a.DeleteServiceHandler)
+			r.Post("/scale", a.ScaleServiceHandler)
+			r.Get("/history", a.GetServiceHistoryHandler)
+			r.Post("/rollback", a.RollbackServiceHandler)
+			r.Post("/promote", a.PromoteServiceHandler)
+			r.Post("/abort", a.AbortServiceHandler)
+		})
+	})
+	router.Route("/jobs", func(r chi.Router) {
+		r.Post("/", a.CreateJobHandler)
+		r.Get("/", a.GetJobsHandler)
+		r.Route("/{name}", func(r chi.Router) {
+			r.Get("/", a.GetJobHandler)
+			r.Delete("/", a.DeleteJobHandler)
+		})
+	})
+	router.Route("/cronjobs", func(r chi.Router) {
+		r.Post("/", a.CreateCronJobHandler)
+		r.Get("/", a.GetCronJobsHandler)
+		r.Route("/{name}", func(r chi.Router) {
+			r.Delete("/", a.DeleteCronJobHandler)
+		})
+	})
+	router.Route("/workflows", func(r chi.Router) {
+		r.Post("/", a.CreateWorkflowHandler)
+		r.Get("/", a.GetWorkflowsHandler)
+		r.Route("/{name}", func(r chi.Router) {
+			r.Get("/", a.GetWorkflowHandler)
+			r.Delete("/", a.DeleteWorkflowHandler)
+		})
+	})
 }
 
 func (a *Api) Start() {
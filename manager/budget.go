@@ -0,0 +1,65 @@
+package manager
+
+import (
+	"log"
+
+	"github.com/d-bolshakov/orchestrator/budget"
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/google/uuid"
+)
+
+func (m *Manager) AddBudget(b *budget.DisruptionBudget) error {
+	return m.BudgetDb.Put(b.Name, b)
+}
+
+func (m *Manager) GetBudgets() []*budget.DisruptionBudget {
+	budgets, err := m.BudgetDb.List()
+	if err != nil {
+		log.Printf("Error getting list of disruption budgets: %v\n", err)
+		return nil
+	}
+	return budgets
+}
+
+func (m *Manager) DeleteBudget(name string) error {
+	return m.BudgetDb.Delete(name)
+}
+
+// checkDisruptionBudgets returns an error if voluntarily stopping the tasks
+// would violate any of the disruption budgets they are covered by. The tasks
+// are checked as if they were stopped one after the other.
+func (m *Manager) checkDisruptionBudgets(stopping ...*task.Task) error {
+	tasks := m.GetTasks()
+	for _, b := range m.GetBudgets() {
+		expected, healthy := 0, 0
+		for _, member := range tasks {
+			// Tasks that are gone, like failed tasks a service has replaced,
+			// aren't expected back, and copies of tasks being moved stand for
+			// the tasks they replace.
+			if !b.Matches(member) || !isActive(member) || member.MigratedFrom != uuid.Nil {
+				continue
+			}
+
+			expected++
+			if m.isTaskHealthy(member) {
+				healthy++
+			}
+		}
+
+		for _, t := range stopping {
+			if !b.Matches(t) || !m.isTaskHealthy(t) {
+				continue
+			}
+
+			err := b.AllowEviction(expected, healthy)
+			if err != nil {
+				return err
+			}
+			// A stopped task is no longer expected back.
+			expected--
+			healthy--
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/job"
+)
+
+func (m *Manager) CreateCronJob(c *job.CronJob) error {
+	_, err := m.CronJobDb.Get(c.Name)
+	if err == nil {
+		return fmt.Errorf("cron job %s already exists", c.Name)
+	}
+
+	c.Created = time.Now().UTC()
+	c.LastScheduleTime = time.Time{}
+	c.NextScheduleTime = time.Time{}
+	c.Message = ""
+	return m.CronJobDb.Put(c.Name, c)
+}
+
+func (m *Manager) GetCronJobs() []*job.CronJob {
+	cronJobs, err := m.CronJobDb.List()
+	if err != nil {
+		log.Printf("Error getting list of cron jobs: %v\n", err)
+		return nil
+	}
+	return cronJobs
+}
+
+// DeleteCronJob removes the cron job together with the jobs it created.
+func (m *Manager) DeleteCronJob(name string) error {
+	_, err := m.CronJobDb.Get(name)
+	if err != nil {
+		return err
+	}
+
+	for _, j := range m.cronJobJobs(name) {
+		m.DeleteJob(j.Name)
+	}
+
+	return m.CronJobDb.Delete(name)
+}
+
+func (m *Manager) RunCronJobs() {
+	for {
+		log.Println("Checking cron job schedules")
+		m.mu.Lock()
+		m.runCronJobs()
+		m.mu.Unlock()
+		log.Println("Cron job scheduling completed")
+		log.Println("Sleeping for 10 seconds")
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func (m *Manager) runCronJobs() {
+	for _, c := range m.GetCronJobs() {
+		m.runCronJob(c)
+	}
+}
+
+// runCronJob creates a job if the schedule fired since the last run. When
+// several runs were missed, e.g. while the manager was down, only the most
+// recent one is started.
+func (m *Manager) runCronJob(c *job.CronJob) {
+	defer m.CronJobDb.Put(c.Name, c)
+	spec := c.WithDefaults()
+
+	schedule, err := job.ParseSchedule(c.Schedule)
+	if err != nil {
+		c.Message = fmt.Sprintf("invalid schedule: %v", err)
+		return
+	}
+	loc, err := c.Location()
+	if err != nil {
+		c.Message = fmt.Sprintf("invalid time zone: %v", err)
+		return
+	}
+
+	now := time.Now().In(loc)
+	last := c.LastScheduleTime
+	if last.IsZero() {
+		last = c.Created
+	}
+
+	var scheduled time.Time
+	for t := schedule.Next(last.In(loc)); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+		scheduled = t
+	}
+	c.NextScheduleTime = schedule.Next(now).UTC()
+
+	if scheduled.IsZero() {
+		return
+	}
+	c.LastScheduleTime = scheduled.UTC()
+
+	deadline := time.Duration(spec.StartingDeadlineSeconds) * time.Second
+	if deadline > 0 && now.Sub(scheduled) > deadline {
+		c.Message = fmt.Sprintf("missed the run scheduled at %s", scheduled.Format(time.RFC3339))
+		log.Printf("Cron job %s %s\n", c.Name, c.Message)
+		return
+	}
+
+	active := []*job.Job{}
+	for _, j := range m.cronJobJobs(c.Name) {
+		if !j.Finished() {
+			active = append(active, j)
+		}
+	}
+
+	if len(active) > 0 {
+		switch spec.ConcurrencyPolicy {
+		case job.ConcurrencyForbid:
+			c.Message = fmt.Sprintf("skipped the run scheduled at %s, previous job is still running", scheduled.Format(time.RFC3339))
+			log.Printf("Cron job %s %s\n", c.Name, c.Message)
+			return
+
+		case job.ConcurrencyReplace:
+			for _, j := range active {
+				log.Printf("Replacing job %s of cron job %s\n", j.Name, c.Name)
+				m.DeleteJob(j.Name)
+			}
+		}
+	}
+
+	j := c.NewJob(scheduled)
+	err = m.CreateJob(j)
+	if err != nil {
+		c.Message = fmt.Sprintf("error creating job for the run scheduled at %s: %v", scheduled.Format(time.RFC3339), err)
+		log.Printf("Cron job %s %s\n", c.Name, c.Message)
+		return
+	}
+
+	c.Message = ""
+	log.Printf("Cron job %s started job %s\n", c.Name, j.Name)
+	m.cleanUpCronJobHistory(&spec)
+}
+
+// cleanUpCronJobHistory removes the oldest finished jobs of the cron job, and
+// their tasks, beyond the history limits. The tasks are removed from their
+// workers too, which would otherwise keep reporting them.
+func (m *Manager) cleanUpCronJobHistory(c *job.CronJob) {
+	succeeded, failed := []*job.Job{}, []*job.Job{}
+	for _, j := range m.cronJobJobs(c.Name) {
+		switch j.Status.State {
+		case job.Succeeded:
+			succeeded = append(succeeded, j)
+		case job.Failed:
+			failed = append(failed, j)
+		}
+	}
+
+	m.trimJobHistory(succeeded, *c.SuccessfulJobsHistoryLimit)
+	m.trimJobHistory(failed, *c.FailedJobsHistoryLimit)
+}
+
+func (m *Manager) trimJobHistory(jobs []*job.Job, limit int) {
+	if limit < 0 || len(jobs) <= limit {
+		return
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Status.CompletionTime.After(jobs[j].Status.CompletionTime)
+	})
+
+	for _, j := range jobs[limit:] {
+		for _, t := range m.jobTasks(j.Name) {
+			if !isActive(t) {
+				m.removeFromWorker(t)
+				m.unassignTask(t)
+				m.TaskDb.Delete(t.ID.String())
+			}
+		}
+		m.JobDb.Delete(j.Name)
+		log.Printf("Removed job %s from history\n", j.Name)
+	}
+}
+
+func (m *Manager) cronJobJobs(name string) []*job.Job {
+	jobs := []*job.Job{}
+	for _, j := range m.GetJobs() {
+		if j.CronJob == name {
+			jobs = append(jobs, j)
+		}
+	}
+
+	return jobs
+}
@@ -0,0 +1,323 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/client"
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/google/uuid"
+)
+
+// Rebalance periodically looks for workers that are noticeably busier than
+// the rest of the cluster and moves a bounded number of tasks to the nodes
+// the scheduler would choose for them today. In between, it finishes the
+// moves it started.
+func (m *Manager) Rebalance() {
+	lastRun := time.Time{}
+	for {
+		m.mu.Lock()
+		m.finishMigrations()
+		if time.Since(lastRun) >= m.RebalanceInterval {
+			log.Println("Checking cluster balance")
+			m.rebalance()
+			log.Printf("Rebalancing completed, next check in %v\n", m.RebalanceInterval)
+			lastRun = time.Now()
+		}
+		m.mu.Unlock()
+		time.Sleep(15 * time.Second)
+	}
+}
+
+func (m *Manager) rebalance() {
+	for _, t := range m.GetTasks() {
+		if t.MigratedFrom != uuid.Nil {
+			log.Printf("Task %s is still being moved, not rebalancing\n", t.MigratedFrom)
+			return
+		}
+	}
+
+	load := m.workerLoads()
+	for i := 0; i < m.MaxMigrations; i++ {
+		busiest, idlest := m.loadExtremes(load)
+		if !m.imbalanced(busiest, idlest) {
+			log.Println("Cluster is balanced, nothing to migrate")
+			return
+		}
+
+		migrated := false
+		for _, t := range busiest.tasks {
+			err := m.checkDisruptionBudgets(t)
+			if err != nil {
+				log.Printf("Not migrating task %s: %v\n", t.ID, err)
+				continue
+			}
+
+			target, err := m.SelectWorker(*t)
+			if err != nil {
+				log.Printf("Error selecting worker for task %s: %v\n", t.ID, err)
+				continue
+			}
+
+			// Only move the task if it makes the cluster more even, otherwise the
+			// next cycle would just move it back.
+			dest, ok := load[target.Name]
+			if !ok || dest == busiest || !improves(t, busiest, dest) {
+				continue
+			}
+
+			_, err = m.migrateTask(t, target.Name)
+			if err != nil {
+				log.Printf("Error migrating task %s from %s to %s: %v\n", t.ID, busiest.name, target.Name, err)
+				continue
+			}
+
+			move(t, busiest, dest)
+			migrated = true
+			break
+		}
+
+		if !migrated {
+			log.Printf("No task on worker %s can be moved to a less loaded worker\n", busiest.name)
+			return
+		}
+	}
+}
+
+// workerLoad is how busy a worker is: the running tasks placed on it and the
+// share of its CPU, memory and disk in use, as of the stats last collected.
+type workerLoad struct {
+	name        string
+	tasks       []*task.Task
+	hasStats    bool
+	cpu         float64
+	cores       float64
+	memory      float64
+	disk        float64
+	memoryTotal float64
+	diskTotal   float64
+}
+
+// utilization is the share of the most used resource of the worker.
+func (l *workerLoad) utilization() float64 {
+	return max(l.cpu, l.memory, l.disk)
+}
+
+// workerLoads returns the load of every worker. The running tasks of a
+// worker are sorted with the most recently started first, so they are the
+// first to be moved. Tasks of jobs and workflows are left out, since they
+// run to completion and a copy would run them twice.
+func (m *Manager) workerLoads() map[string]*workerLoad {
+	load := make(map[string]*workerLoad)
+	for _, n := range m.WorkerNodes {
+		l := &workerLoad{name: n.Name, tasks: []*task.Task{}}
+		stats := n.Stats
+		if stats.MemStats != nil && stats.DiskStats != nil && stats.CpuStats != nil &&
+			stats.MemTotalKb() > 0 && stats.DiskTotal() > 0 {
+			l.hasStats = true
+			l.cpu = stats.CpuUsage()
+			l.cores = float64(stats.Cores)
+			l.memoryTotal = float64(stats.MemTotalKb()) * 1024
+			l.memory = float64(stats.MemUsedKb()) * 1024 / l.memoryTotal
+			l.diskTotal = float64(stats.DiskTotal())
+			l.disk = float64(stats.DiskUsed()) / l.diskTotal
+		}
+		load[n.Name] = l
+	}
+
+	for _, t := range m.GetTasks() {
+		if t.State != task.Running || t.Kind == task.KindJob {
+			continue
+		}
+
+		w, ok := m.TaskWorkerMap[t.ID]
+		if !ok || load[w] == nil {
+			continue
+		}
+		load[w].tasks = append(load[w].tasks, t)
+	}
+
+	for _, l := range load {
+		sort.Slice(l.tasks, func(i, j int) bool {
+			return l.tasks[i].StartTime.After(l.tasks[j].StartTime)
+		})
+	}
+
+	return load
+}
+
+// loadExtremes returns the busiest and the idlest worker, compared by
+// utilization when all workers have reported stats, and by the number of
+// tasks they run otherwise.
+func (m *Manager) loadExtremes(load map[string]*workerLoad) (*workerLoad, *workerLoad) {
+	byUtilization := true
+	for _, l := range load {
+		byUtilization = byUtilization && l.hasStats
+	}
+
+	var busiest, idlest *workerLoad
+	for _, w := range m.Workers {
+		l, ok := load[w]
+		if !ok {
+			continue
+		}
+		if busiest == nil || busier(l, busiest, byUtilization) {
+			busiest = l
+		}
+		if idlest == nil || busier(idlest, l, byUtilization) {
+			idlest = l
+		}
+	}
+
+	return busiest, idlest
+}
+
+func busier(a *workerLoad, b *workerLoad, byUtilization bool) bool {
+	if byUtilization {
+		return a.utilization() > b.utilization()
+	}
+	return len(a.tasks) > len(b.tasks)
+}
+
+// imbalanced tells whether the busiest worker is more than RebalanceSkew
+// more utilized than the idlest one, or runs more than RebalanceThreshold
+// more tasks when their utilization isn't known.
+func (m *Manager) imbalanced(busiest *workerLoad, idlest *workerLoad) bool {
+	if busiest == nil || idlest == nil || busiest == idlest {
+		return false
+	}
+	if busiest.hasStats && idlest.hasStats {
+		return busiest.utilization()-idlest.utilization() > m.RebalanceSkew
+	}
+	return len(busiest.tasks)-len(idlest.tasks) > m.RebalanceThreshold
+}
+
+// improves tells whether moving the task leaves the target worker no busier
+// than the source, so that the move won't be undone later.
+func improves(t *task.Task, from *workerLoad, to *workerLoad) bool {
+	after := map[*workerLoad]*workerLoad{from: nil, to: nil}
+	for l := range after {
+		c := *l
+		c.tasks = append([]*task.Task{}, l.tasks...)
+		after[l] = &c
+	}
+	move(t, after[from], after[to])
+
+	return !busier(after[to], after[from], from.hasStats && to.hasStats)
+}
+
+// move updates the loads as if the task had been moved between the workers.
+// A task is taken to use the CPUs it asks for. The CPU used by a task that
+// doesn't ask for any isn't known, it is taken to be an even share of what
+// its worker uses.
+func move(t *task.Task, from *workerLoad, to *workerLoad) {
+	if t.TotalCpu() > 0 && from.cores > 0 && to.cores > 0 {
+		from.cpu -= t.TotalCpu() / from.cores
+		to.cpu += t.TotalCpu() / to.cores
+	} else if len(from.tasks) > 0 {
+		cpu := from.cpu / float64(len(from.tasks))
+		from.cpu -= cpu
+		to.cpu += cpu
+	}
+	if from.memoryTotal > 0 && to.memoryTotal > 0 {
+		from.memory -= float64(t.TotalMemory()) / from.memoryTotal
+		to.memory += float64(t.TotalMemory()) / to.memoryTotal
+	}
+	if from.diskTotal > 0 && to.diskTotal > 0 {
+		from.disk -= float64(t.TotalDisk()) / from.diskTotal
+		to.disk += float64(t.TotalDisk()) / to.diskTotal
+	}
+
+	from.tasks = removeTask(from.tasks, t.ID)
+	to.tasks = append(to.tasks, t)
+}
+
+// migrateTask starts a copy of the task on the target worker. The original
+// keeps running until finishMigrations sees the copy ready. The copy gets a
+// new ID so that updates from the old worker don't overwrite its state.
+func (m *Manager) migrateTask(t *task.Task, target string) (*task.Task, error) {
+	source := m.TaskWorkerMap[t.ID]
+
+	newTask := *t
+	newTask.ID = uuid.New()
+	newTask.MigratedFrom = t.ID
+	newTask.State = task.Pending
+	newTask.Transitions = nil
+	newTask.SetState(task.Scheduled, task.ReasonMigrated, fmt.Sprintf("moved from task %s on worker %s to worker %s", t.ID, source, target))
+	newTask.SubmitTime = time.Now().UTC()
+	newTask.ContainerID = ""
+	newTask.SidecarContainerIDs = nil
+	newTask.HostPorts = nil
+	newTask.StartTime = time.Time{}
+	newTask.FinishTime = time.Time{}
+	newTask.RestartCount = 0
+	newTask.ResetProbes()
+
+	te := task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Running,
+		Timestamp: time.Now(),
+		Task:      newTask,
+	}
+
+	client := client.New(target, "worker")
+	_, err := client.SendTask(te)
+	if err != nil {
+		return nil, fmt.Errorf("error sending task %s to worker %s: %v", newTask.ID, target, err)
+	}
+
+	m.WorkerTaskMap[target] = append(m.WorkerTaskMap[target], newTask.ID)
+	m.TaskWorkerMap[newTask.ID] = target
+	m.TaskDb.Put(newTask.ID.String(), &newTask)
+	m.EventDb.Put(te.ID.String(), &te)
+
+	log.Printf("Moving task %s from worker %s to worker %s as task %s\n", t.ID, source, target, newTask.ID)
+
+	return &newTask, nil
+}
+
+// finishMigrations stops the tasks being moved once their copies are ready.
+// A copy that fails, or isn't ready within MigrationTimeout, is stopped
+// instead and the original keeps running. A copy whose original is asked to
+// stop in the meantime is stopped along with it.
+func (m *Manager) finishMigrations() {
+	for _, c := range m.GetTasks() {
+		if c.MigratedFrom == uuid.Nil {
+			continue
+		}
+
+		original, err := m.TaskDb.Get(c.MigratedFrom.String())
+		switch {
+		case err != nil || (!isActive(original) && !original.StopRequested):
+			log.Printf("Task %s is gone, task %s replaces it\n", c.MigratedFrom, c.ID)
+		case original.StopRequested:
+			log.Printf("Task %s was stopped while being moved, stopping its copy %s\n", original.ID, c.ID)
+			m.requestStop(c)
+		case m.isTaskHealthy(c):
+			log.Printf("Task %s is ready on its new worker, stopping task %s\n", c.ID, original.ID)
+			m.requestStop(original)
+		case !isActive(c) || time.Since(c.SubmitTime) > m.MigrationTimeout:
+			log.Printf("Task %s did not become ready, task %s stays where it is\n", c.ID, original.ID)
+			if isActive(c) {
+				m.requestStop(c)
+			}
+		default:
+			continue
+		}
+
+		c.MigratedFrom = uuid.Nil
+		m.TaskDb.Put(c.ID.String(), c)
+	}
+}
+
+func removeTask(tasks []*task.Task, id uuid.UUID) []*task.Task {
+	for i, t := range tasks {
+		if t.ID == id {
+			return append(tasks[:i], tasks[i+1:]...)
+		}
+	}
+
+	return tasks
+}
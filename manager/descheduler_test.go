@@ -0,0 +1,65 @@
+package manager
+
+import (
+	"math"
+	"testing"
+
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/google/uuid"
+)
+
+func newLoad(name string, cpu float64, cores float64, tasks ...*task.Task) *workerLoad {
+	return &workerLoad{name: name, tasks: tasks, hasStats: true, cpu: cpu, cores: cores, memoryTotal: 1000, diskTotal: 1000}
+}
+
+func TestMove(t *testing.T) {
+	requesting := &task.Task{ID: uuid.New(), Cpu: 1, Sidecars: []task.Container{{Name: "proxy", Cpu: 1}}, Memory: 100}
+	other := &task.Task{ID: uuid.New()}
+	tests := []struct {
+		name     string
+		task     *task.Task
+		from     *workerLoad
+		to       *workerLoad
+		wantFrom float64
+		wantTo   float64
+	}{
+		{"requested cpu", requesting, newLoad("a", 0.75, 4, requesting, other), newLoad("b", 0.25, 8), 0.25, 0.5},
+		{"no request", other, newLoad("a", 0.8, 4, requesting, other), newLoad("b", 0.2, 4), 0.4, 0.6},
+		{"cores unknown", requesting, newLoad("a", 0.8, 0, requesting, other), newLoad("b", 0.2, 4), 0.4, 0.6},
+	}
+
+	for _, tt := range tests {
+		move(tt.task, tt.from, tt.to)
+		if math.Abs(tt.from.cpu-tt.wantFrom) > 1e-9 || math.Abs(tt.to.cpu-tt.wantTo) > 1e-9 {
+			t.Errorf("%s: cpu after move is %v and %v, want %v and %v", tt.name, tt.from.cpu, tt.to.cpu, tt.wantFrom, tt.wantTo)
+		}
+		if len(tt.from.tasks) != 1 || len(tt.to.tasks) != 1 || tt.to.tasks[0] != tt.task {
+			t.Errorf("%s: task was not moved between the loads", tt.name)
+		}
+	}
+}
+
+func TestImproves(t *testing.T) {
+	big := &task.Task{ID: uuid.New(), Cpu: 3}
+	small := &task.Task{ID: uuid.New(), Cpu: 0.5}
+	tests := []struct {
+		name string
+		task *task.Task
+		from *workerLoad
+		to   *workerLoad
+		want bool
+	}{
+		{"evens out", small, newLoad("a", 0.9, 4, big, small), newLoad("b", 0.1, 4), true},
+		{"overshoots", big, newLoad("a", 0.9, 4, big, small), newLoad("b", 0.1, 4), false},
+	}
+
+	for _, tt := range tests {
+		got := improves(tt.task, tt.from, tt.to)
+		if got != tt.want {
+			t.Errorf("%s: improves() = %v, want %v", tt.name, got, tt.want)
+		}
+		if len(tt.from.tasks) != 2 || len(tt.to.tasks) != 0 {
+			t.Errorf("%s: improves() changed the loads", tt.name)
+		}
+	}
+}
@@ -3,11 +3,18 @@ package manager
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/d-bolshakov/orchestrator/budget"
+	"github.com/d-bolshakov/orchestrator/client"
+	"github.com/d-bolshakov/orchestrator/job"
+	"github.com/d-bolshakov/orchestrator/service"
 	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/d-bolshakov/orchestrator/utils"
+	"github.com/d-bolshakov/orchestrator/workflow"
 	"github.com/go-chi/chi"
 	"github.com/google/uuid"
 )
@@ -24,6 +31,9 @@ func (a *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
 	te := task.TaskEvent{}
 
 	err := d.Decode(&te)
+	if err == nil {
+		err = te.Task.Validate()
+	}
 	if err != nil {
 		msg := fmt.Sprintf("Error unmarshalling body: %v\n", err)
 		log.Printf(msg)
@@ -36,7 +46,7 @@ func (a *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	a.Manager.AddTask(te)
+	a.Manager.submitTask(te.Task)
 	log.Printf("Added task %v\n", te.Task.ID)
 	w.WriteHeader(201)
 	json.NewEncoder(w).Encode(te.Task)
@@ -48,6 +58,21 @@ func (a *Api) GetTasksHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(a.Manager.GetTasks())
 }
 
+func (a *Api) GetTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "taskID")
+
+	t, err := a.Manager.TaskDb.Get(taskID)
+	if err != nil {
+		log.Printf("Error retrieving task %s: %v\n", taskID, err)
+		w.WriteHeader(404)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(t)
+}
+
 func (a *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
 	taskID := chi.URLParam(r, "taskID")
 	if taskID == "" {
@@ -71,6 +96,26 @@ func (a *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
 		Timestamp: time.Now(),
 	}
 
+	if r.URL.Query().Get("force") != "true" {
+		err = a.Manager.checkDisruptionBudgets(taskToStop)
+		if err != nil {
+			msg := fmt.Sprintf("Refusing to stop task %s: %v", tID, err)
+			log.Println(msg)
+			w.WriteHeader(409)
+			e := ErrResponse{
+				HTTPStatusCode: 409,
+				Message:        msg,
+			}
+			json.NewEncoder(w).Encode(e)
+			return
+		}
+	}
+
+	// Marking the task first keeps it from being restarted, and drops it if
+	// it is still waiting on the queue.
+	taskToStop.StopRequested = true
+	a.Manager.TaskDb.Put(taskToStop.ID.String(), taskToStop)
+
 	taskCopy := *taskToStop
 	taskCopy.State = task.Completed
 	te.Task = taskCopy
@@ -80,8 +125,885 @@ func (a *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(204)
 }
 
+// GetTaskLogsHandler streams the logs of a task from the worker running it.
+func (a *Api) GetTaskLogsHandler(w http.ResponseWriter, r *http.Request) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		log.Printf("Invalid taskID passed in request: %v\n", err)
+		w.WriteHeader(400)
+		return
+	}
+
+	t, workerAddress, ok, err := a.Manager.taskWorker(tID)
+	if err != nil {
+		log.Printf("Error occurred retrieving task %s from DB: %v\n", tID, err)
+		w.WriteHeader(404)
+		return
+	}
+
+	opts, err := task.ParseLogOptions(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        err.Error(),
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	if !ok {
+		msg := fmt.Sprintf("Task %s is %v and has no logs on any worker", tID, t.State)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	logs, err := client.New(workerAddress, "worker").TaskLogs(r.Context(), tID.String(), opts)
+	if err != nil {
+		msg := fmt.Sprintf("Error reading logs of task %s: %v", tID, err)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+	defer logs.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(200)
+	io.Copy(utils.FlushWriter{W: w}, logs)
+}
+
+func (a *Api) GetTaskArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	a.archiveRequest(w, r, func(c *client.Client, taskID string, container string, p string) error {
+		archive, err := c.CopyFromTask(r.Context(), taskID, container, p)
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+
+		w.Header().Set("Content-Type", "application/x-tar")
+		w.WriteHeader(200)
+		io.Copy(w, archive)
+		return nil
+	})
+}
+
+func (a *Api) PutTaskArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	a.archiveRequest(w, r, func(c *client.Client, taskID string, container string, p string) error {
+		err := c.CopyToTask(r.Context(), taskID, container, p, r.Body)
+		if err != nil {
+			return err
+		}
+
+		w.WriteHeader(204)
+		return nil
+	})
+}
+
+// archiveRequest passes a request to copy files from or to a task on to the
+// worker of the task. do answers the request unless it fails.
+func (a *Api) archiveRequest(w http.ResponseWriter, r *http.Request, do func(c *client.Client, taskID string, container string, p string) error) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		log.Printf("Invalid taskID passed in request: %v\n", err)
+		w.WriteHeader(400)
+		return
+	}
+
+	t, workerAddress, ok, err := a.Manager.taskWorker(tID)
+	if err != nil {
+		log.Printf("Error occurred retrieving task %s from DB: %v\n", tID, err)
+		w.WriteHeader(404)
+		return
+	}
+
+	p := r.URL.Query().Get("path")
+	if p == "" {
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        "no path to copy",
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	if !ok {
+		msg := fmt.Sprintf("Task %s is %v and has no container on any worker", tID, t.State)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	err = do(client.New(workerAddress, "worker"), tID.String(), r.URL.Query().Get("container"), p)
+	if err != nil {
+		msg := fmt.Sprintf("Error copying %s of task %s: %v", p, tID, err)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+}
+
+func (a *Api) ExecTaskHandler(w http.ResponseWriter, r *http.Request) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		log.Printf("Invalid taskID passed in request: %v\n", err)
+		w.WriteHeader(400)
+		return
+	}
+
+	t, workerAddress, ok, err := a.Manager.taskWorker(tID)
+	if err != nil {
+		log.Printf("Error occurred retrieving task %s from DB: %v\n", tID, err)
+		w.WriteHeader(404)
+		return
+	}
+
+	opts, err := task.ParseExecOptions(r.URL.Query())
+	if err == nil && !utils.IsUpgrade(r) {
+		err = fmt.Errorf("exec needs the connection to be upgraded to tcp")
+	}
+	if err != nil {
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        err.Error(),
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	if !ok {
+		msg := fmt.Sprintf("Task %s is %v and not running on any worker", tID, t.State)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	session, err := client.New(workerAddress, "worker").Exec(tID.String(), opts)
+	if err != nil {
+		msg := fmt.Sprintf("Error running command in task %s: %v", tID, err)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	stream, err := utils.Upgrade(w, http.Header{"X-Exec-Id": {session.ID}})
+	if err != nil {
+		log.Printf("Error upgrading connection for command %s: %v\n", session.ID, err)
+		session.Close()
+		return
+	}
+
+	utils.Splice(stream, session.Stream)
+}
+
+func (a *Api) ResizeExecHandler(w http.ResponseWriter, r *http.Request) {
+	height, width, err := task.ParseTerminalSize(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        err.Error(),
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	a.execRequest(w, r, func(c *client.Client, taskID string, execID string) (any, error) {
+		return nil, c.ResizeExec(taskID, execID, height, width)
+	})
+}
+
+func (a *Api) GetExecHandler(w http.ResponseWriter, r *http.Request) {
+	a.execRequest(w, r, func(c *client.Client, taskID string, execID string) (any, error) {
+		return c.InspectExec(taskID, execID)
+	})
+}
+
+// execRequest passes a request about a command running in a task on to the
+// worker of the task, answering with the result of do, or 204 if it has
+// none.
+func (a *Api) execRequest(w http.ResponseWriter, r *http.Request, do func(c *client.Client, taskID string, execID string) (any, error)) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		log.Printf("Invalid taskID passed in request: %v\n", err)
+		w.WriteHeader(400)
+		return
+	}
+
+	workerAddress, ok := a.Manager.TaskWorkerMap[tID]
+	if !ok {
+		msg := fmt.Sprintf("Task %s is not running on any worker", tID)
+		log.Println(msg)
+		w.WriteHeader(404)
+		e := ErrResponse{
+			HTTPStatusCode: 404,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	execID := chi.URLParam(r, "execID")
+	result, err := do(client.New(workerAddress, "worker"), tID.String(), execID)
+	if err != nil {
+		msg := fmt.Sprintf("Error with command %s of task %s: %v", execID, tID, err)
+		log.Println(msg)
+		w.WriteHeader(404)
+		e := ErrResponse{
+			HTTPStatusCode: 404,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	if result == nil {
+		w.WriteHeader(204)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(result)
+}
+
+func (a *Api) PauseTaskHandler(w http.ResponseWriter, r *http.Request) {
+	a.taskAction(w, r, "pause", task.Paused, task.ReasonPaused, (*client.Client).PauseTask)
+}
+
+func (a *Api) ResumeTaskHandler(w http.ResponseWriter, r *http.Request) {
+	a.taskAction(w, r, "resume", task.Running, task.ReasonResumed, (*client.Client).ResumeTask)
+}
+
+// taskAction passes a pause or resume request on to the worker running the
+// task, and records the new state right away instead of waiting for the next
+// update from the worker.
+func (a *Api) taskAction(w http.ResponseWriter, r *http.Request, action string, state task.State, reason string, do func(*client.Client, string) error) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		log.Printf("Invalid taskID passed in request: %v\n", err)
+		w.WriteHeader(400)
+		return
+	}
+
+	t, err := a.Manager.TaskDb.Get(tID.String())
+	if err != nil {
+		log.Printf("Error occurred retrieving task %s from DB: %v\n", tID, err)
+		w.WriteHeader(404)
+		return
+	}
+
+	workerAddress, ok := a.Manager.TaskWorkerMap[tID]
+	if !ok {
+		msg := fmt.Sprintf("Cannot %s task %s: task is %v and not running on any worker", action, tID, t.State)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	err = do(client.New(workerAddress, "worker"), tID.String())
+	if err != nil {
+		msg := fmt.Sprintf("Cannot %s task %s: %v", action, tID, err)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	t.SetState(state, reason, "")
+	if state == task.Paused {
+		t.Ready = false
+	}
+	a.Manager.TaskDb.Put(t.ID.String(), t)
+
+	log.Printf("Task %v: %s done on worker %s\n", tID, action, workerAddress)
+	w.WriteHeader(204)
+}
+
 func (a *Api) GetNodesHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 	json.NewEncoder(w).Encode(a.Manager.WorkerNodes)
 }
+
+func (a *Api) CreateBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	b := budget.DisruptionBudget{}
+	err := d.Decode(&b)
+	if err == nil {
+		err = b.Validate()
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error unmarshalling body: %v", err)
+		log.Println(msg)
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	err = a.Manager.AddBudget(&b)
+	if err != nil {
+		log.Printf("Error storing disruption budget %s: %v\n", b.Name, err)
+		w.WriteHeader(500)
+		return
+	}
+
+	log.Printf("Added disruption budget %s\n", b.Name)
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(b)
+}
+
+func (a *Api) GetBudgetsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(a.Manager.GetBudgets())
+}
+
+func (a *Api) DeleteBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	err := a.Manager.DeleteBudget(name)
+	if err != nil {
+		log.Printf("Error deleting disruption budget %s: %v\n", name, err)
+		w.WriteHeader(404)
+		return
+	}
+
+	log.Printf("Deleted disruption budget %s\n", name)
+	w.WriteHeader(204)
+}
+
+func (a *Api) CreateServiceHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	s := service.Service{}
+	err := d.Decode(&s)
+	if err == nil {
+		err = s.Validate()
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error unmarshalling body: %v", err)
+		log.Println(msg)
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	err = a.Manager.CreateService(&s)
+	if err != nil {
+		msg := fmt.Sprintf("Error creating service %s: %v", s.Name, err)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	log.Printf("Added service %s\n", s.Name)
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(s)
+}
+
+func (a *Api) GetServicesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(a.Manager.GetServices())
+}
+
+func (a *Api) GetServiceHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	s, err := a.Manager.ServiceDb.Get(name)
+	if err != nil {
+		log.Printf("Error retrieving service %s: %v\n", name, err)
+		w.WriteHeader(404)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(s)
+}
+
+type ScaleRequest struct {
+	Replicas int
+}
+
+func (a *Api) ScaleServiceHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	req := ScaleRequest{}
+	err := d.Decode(&req)
+	if err != nil {
+		msg := fmt.Sprintf("Error unmarshalling body: %v", err)
+		log.Println(msg)
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	err = a.Manager.ScaleService(name, req.Replicas)
+	if err != nil {
+		msg := fmt.Sprintf("Error scaling service %s: %v", name, err)
+		log.Println(msg)
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	log.Printf("Scaled service %s to %d replicas\n", name, req.Replicas)
+	w.WriteHeader(204)
+}
+
+// DeleteServiceHandler removes a service. It refuses to if stopping the tasks
+// of the service would violate a disruption budget, unless force=true is
+// given.
+func (a *Api) DeleteServiceHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	_, err := a.Manager.ServiceDb.Get(name)
+	if err != nil {
+		log.Printf("Error deleting service %s: %v\n", name, err)
+		w.WriteHeader(404)
+		return
+	}
+
+	err = a.Manager.DeleteService(name, r.URL.Query().Get("force") == "true")
+	if err != nil {
+		msg := fmt.Sprintf("Refusing to delete service %s: %v", name, err)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	log.Printf("Deleted service %s\n", name)
+	w.WriteHeader(204)
+}
+
+func (a *Api) UpdateServiceHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	s := service.Service{}
+	err := d.Decode(&s)
+	if err == nil {
+		s.Name = name
+		err = s.Validate()
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error unmarshalling body: %v", err)
+		log.Println(msg)
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	_, err = a.Manager.ServiceDb.Get(name)
+	if err != nil {
+		msg := fmt.Sprintf("No service %s found", name)
+		log.Println(msg)
+		w.WriteHeader(404)
+		e := ErrResponse{
+			HTTPStatusCode: 404,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	err = a.Manager.UpdateService(name, &s)
+	if err != nil {
+		msg := fmt.Sprintf("Error updating service %s: %v", name, err)
+		log.Println(msg)
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	log.Printf("Updated service %s\n", name)
+	w.WriteHeader(204)
+}
+
+func (a *Api) GetServiceHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	history, err := a.Manager.GetServiceHistory(name)
+	if err != nil {
+		log.Printf("Error retrieving history of service %s: %v\n", name, err)
+		w.WriteHeader(404)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(history)
+}
+
+type RollbackRequest struct {
+	Revision int
+	Author   string
+}
+
+func (a *Api) RollbackServiceHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	req := RollbackRequest{}
+	err := d.Decode(&req)
+	if err != nil {
+		msg := fmt.Sprintf("Error unmarshalling body: %v", err)
+		log.Println(msg)
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	err = a.Manager.RollbackService(name, req.Revision, req.Author)
+	if err != nil {
+		msg := fmt.Sprintf("Error rolling back service %s: %v", name, err)
+		log.Println(msg)
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	log.Printf("Rolling back service %s\n", name)
+	w.WriteHeader(204)
+}
+
+func (a *Api) PromoteServiceHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	err := a.Manager.PromoteService(name)
+	if err != nil {
+		msg := fmt.Sprintf("Error promoting service %s: %v", name, err)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	log.Printf("Promoted service %s\n", name)
+	w.WriteHeader(204)
+}
+
+func (a *Api) AbortServiceHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	err := a.Manager.AbortService(name)
+	if err != nil {
+		msg := fmt.Sprintf("Error aborting update of service %s: %v", name, err)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	log.Printf("Aborted update of service %s\n", name)
+	w.WriteHeader(204)
+}
+
+func (a *Api) CreateJobHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	j := job.Job{}
+	err := d.Decode(&j)
+	if err == nil {
+		err = j.Validate()
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error unmarshalling body: %v", err)
+		log.Println(msg)
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	err = a.Manager.CreateJob(&j)
+	if err != nil {
+		msg := fmt.Sprintf("Error creating job %s: %v", j.Name, err)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	log.Printf("Added job %s\n", j.Name)
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(j)
+}
+
+func (a *Api) GetJobsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(a.Manager.GetJobs())
+}
+
+func (a *Api) GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	j, err := a.Manager.JobDb.Get(name)
+	if err != nil {
+		log.Printf("Error retrieving job %s: %v\n", name, err)
+		w.WriteHeader(404)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(j)
+}
+
+func (a *Api) DeleteJobHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	err := a.Manager.DeleteJob(name)
+	if err != nil {
+		log.Printf("Error deleting job %s: %v\n", name, err)
+		w.WriteHeader(404)
+		return
+	}
+
+	log.Printf("Deleted job %s\n", name)
+	w.WriteHeader(204)
+}
+
+func (a *Api) CreateCronJobHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	c := job.CronJob{}
+	err := d.Decode(&c)
+	if err == nil {
+		err = c.Validate()
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error unmarshalling body: %v", err)
+		log.Println(msg)
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	err = a.Manager.CreateCronJob(&c)
+	if err != nil {
+		msg := fmt.Sprintf("Error creating cron job %s: %v", c.Name, err)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	log.Printf("Added cron job %s\n", c.Name)
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(c)
+}
+
+func (a *Api) GetCronJobsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(a.Manager.GetCronJobs())
+}
+
+func (a *Api) DeleteCronJobHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	err := a.Manager.DeleteCronJob(name)
+	if err != nil {
+		log.Printf("Error deleting cron job %s: %v\n", name, err)
+		w.WriteHeader(404)
+		return
+	}
+
+	log.Printf("Deleted cron job %s\n", name)
+	w.WriteHeader(204)
+}
+
+func (a *Api) CreateWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	wf := workflow.Workflow{}
+	err := d.Decode(&wf)
+	if err == nil {
+		err = wf.Validate()
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error unmarshalling body: %v", err)
+		log.Println(msg)
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	err = a.Manager.CreateWorkflow(&wf)
+	if err != nil {
+		msg := fmt.Sprintf("Error creating workflow %s: %v", wf.Name, err)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	log.Printf("Added workflow %s\n", wf.Name)
+	w.WriteHeader(201)
+	json.NewEncoder(w).Encode(wf)
+}
+
+func (a *Api) GetWorkflowsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(a.Manager.GetWorkflows())
+}
+
+func (a *Api) GetWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	wf, err := a.Manager.WorkflowDb.Get(name)
+	if err != nil {
+		log.Printf("Error retrieving workflow %s: %v\n", name, err)
+		w.WriteHeader(404)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(wf)
+}
+
+func (a *Api) DeleteWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	err := a.Manager.DeleteWorkflow(name)
+	if err != nil {
+		log.Printf("Error deleting workflow %s: %v\n", name, err)
+		w.WriteHeader(404)
+		return
+	}
+
+	log.Printf("Deleted workflow %s\n", name)
+	w.WriteHeader(204)
+}
@@ -0,0 +1,221 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/job"
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/google/uuid"
+)
+
+func (m *Manager) CreateJob(j *job.Job) error {
+	_, err := m.JobDb.Get(j.Name)
+	if err == nil {
+		return fmt.Errorf("job %s already exists", j.Name)
+	}
+
+	j.Status = job.Status{
+		State:     job.Running,
+		StartTime: time.Now().UTC(),
+	}
+	return m.JobDb.Put(j.Name, j)
+}
+
+func (m *Manager) GetJobs() []*job.Job {
+	jobs, err := m.JobDb.List()
+	if err != nil {
+		log.Printf("Error getting list of jobs: %v\n", err)
+		return nil
+	}
+	return jobs
+}
+
+// DeleteJob removes the job and stops its tasks that are still running.
+func (m *Manager) DeleteJob(name string) error {
+	_, err := m.JobDb.Get(name)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range m.jobTasks(name) {
+		if isActive(t) {
+			m.requestStop(t)
+		}
+	}
+
+	return m.JobDb.Delete(name)
+}
+
+func (m *Manager) ReconcileJobs() {
+	for {
+		log.Println("Reconciling jobs")
+		m.mu.Lock()
+		m.reconcileJobs()
+		m.mu.Unlock()
+		log.Println("Job reconciliation completed")
+		log.Println("Sleeping for 10 seconds")
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func (m *Manager) reconcileJobs() {
+	for _, j := range m.GetJobs() {
+		if j.Finished() {
+			continue
+		}
+		m.reconcileJob(j)
+	}
+}
+
+// reconcileJob starts tasks until enough of them have succeeded, keeping at
+// most Parallelism of them active and backing off after failures. Indexed
+// jobs need a successful task for every index and only retry the indexes
+// that haven't succeeded yet. The job fails once more tasks have failed than
+// its backoff limit allows.
+func (m *Manager) reconcileJob(j *job.Job) {
+	defer m.JobDb.Put(j.Name, j)
+	spec := j.WithDefaults()
+
+	active := []*task.Task{}
+	succeeded, failed := 0, 0
+	activeIndexes, succeededIndexes, failedIndexes := map[int]bool{}, map[int]bool{}, map[int]bool{}
+	var lastFailure time.Time
+	for _, t := range m.jobTasks(j.Name) {
+		switch {
+		case isActive(t):
+			active = append(active, t)
+			activeIndexes[t.JobIndex] = true
+		case hasSucceeded(t):
+			succeeded++
+			succeededIndexes[t.JobIndex] = true
+		case t.State == task.Failed:
+			failed++
+			failedIndexes[t.JobIndex] = true
+			if t.FinishTime.After(lastFailure) {
+				lastFailure = t.FinishTime
+			}
+		}
+	}
+
+	if spec.Indexed() {
+		succeeded = len(succeededIndexes)
+		for i := range succeededIndexes {
+			delete(failedIndexes, i)
+		}
+		j.Status.SucceededIndexes = job.FormatIndexes(sortedIndexes(succeededIndexes))
+		j.Status.FailedIndexes = job.FormatIndexes(sortedIndexes(failedIndexes))
+	}
+	j.Status.Active = len(active)
+	j.Status.Succeeded = succeeded
+	j.Status.Failed = failed
+
+	if succeeded >= spec.Completions {
+		m.finishJob(j, job.Succeeded, fmt.Sprintf("%d tasks completed successfully", succeeded), active)
+		return
+	}
+
+	if failed > *spec.BackoffLimit {
+		m.finishJob(j, job.Failed, fmt.Sprintf("%d tasks failed, backoff limit is %d", failed, *spec.BackoffLimit), active)
+		return
+	}
+
+	want := min(spec.Parallelism, spec.Completions-succeeded) - len(active)
+	if want <= 0 {
+		return
+	}
+
+	j.Status.NextRetryTime = lastFailure.Add(spec.Backoff(failed))
+	if time.Now().UTC().Before(j.Status.NextRetryTime) {
+		j.Status.Message = fmt.Sprintf("backing off after %d failures, next retry at %s", failed, j.Status.NextRetryTime.Format(time.RFC3339))
+		return
+	}
+
+	j.Status.Message = ""
+	if !spec.Indexed() {
+		for i := 0; i < want; i++ {
+			m.startJobTask(j, 0)
+		}
+		return
+	}
+
+	for i := 0; i < spec.Indexes() && want > 0; i++ {
+		if activeIndexes[i] || succeededIndexes[i] {
+			continue
+		}
+		m.startJobTask(j, i)
+		want--
+	}
+}
+
+func (m *Manager) finishJob(j *job.Job, state string, message string, active []*task.Task) {
+	for _, t := range active {
+		m.requestStop(t)
+	}
+
+	j.Status.State = state
+	j.Status.Message = message
+	j.Status.CompletionTime = time.Now().UTC()
+	log.Printf("Job %s %s: %s\n", j.Name, state, message)
+}
+
+func (m *Manager) jobTasks(name string) []*task.Task {
+	tasks := []*task.Task{}
+	for _, t := range m.GetTasks() {
+		if t.Job == name {
+			tasks = append(tasks, t)
+		}
+	}
+
+	return tasks
+}
+
+// hasSucceeded reports whether a job or workflow task ran to completion on
+// its own. Tasks that were stopped also end up Completed, but never
+// succeeded.
+func hasSucceeded(t *task.Task) bool {
+	return t.State == task.Completed && t.Reason == task.ReasonCompleted && !t.StopRequested
+}
+
+// startJobTask starts a task of the job. The index is only meaningful for
+// indexed jobs, whose tasks are named after it.
+func (m *Manager) startJobTask(j *job.Job, index int) {
+	t := j.Template
+	t.ID = uuid.New()
+	t.Name = fmt.Sprintf("%s-%s", j.Name, t.ID.String()[:8])
+	t.Kind = task.KindJob
+	t.Job = j.Name
+	if j.Indexed() {
+		t.Name = fmt.Sprintf("%s-%d-%s", j.Name, index, t.ID.String()[:8])
+		t.JobIndex = index
+		t.Env = append(append([]string{}, j.Template.Env...), j.Env(index)...)
+	}
+	t.ContainerID = ""
+	t.HostPorts = nil
+	t.RestartCount = 0
+	t.StopRequested = false
+
+	t.Labels = map[string]string{}
+	for k, v := range j.Template.Labels {
+		t.Labels[k] = v
+	}
+	t.Labels["job"] = j.Name
+	if j.Indexed() {
+		t.Labels["job-index"] = strconv.Itoa(index)
+	}
+
+	m.submitTask(t)
+}
+
+func sortedIndexes(indexes map[int]bool) []int {
+	sorted := []int{}
+	for i := range indexes {
+		sorted = append(sorted, i)
+	}
+	sort.Ints(sorted)
+
+	return sorted
+}
@@ -4,36 +4,93 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"net/http"
-	"strings"
+	"sync"
 	"time"
 
+	"github.com/d-bolshakov/orchestrator/budget"
 	"github.com/d-bolshakov/orchestrator/client"
+	"github.com/d-bolshakov/orchestrator/job"
 	"github.com/d-bolshakov/orchestrator/node"
 	"github.com/d-bolshakov/orchestrator/scheduler"
+	"github.com/d-bolshakov/orchestrator/service"
 	"github.com/d-bolshakov/orchestrator/store"
 	"github.com/d-bolshakov/orchestrator/task"
-	"github.com/docker/go-connections/nat"
+	"github.com/d-bolshakov/orchestrator/workflow"
 	"github.com/golang-collections/collections/queue"
 	"github.com/google/uuid"
 )
 
+// Manager runs its background loops and serves its API one at a time: each
+// pass of a loop and each request holds mu, so they never see the stores or
+// the task maps halfway through a change. Polling the workers for their
+// tasks and stats, and sending them tasks, happens outside of mu, which is
+// only taken to apply the results; every other call to a worker is bounded
+// by client.RequestTimeout.
 type Manager struct {
+	mu sync.Mutex
+
 	Pending       queue.Queue
 	TaskDb        store.Store[*task.Task]
 	EventDb       store.Store[*task.TaskEvent]
+	BudgetDb      store.Store[*budget.DisruptionBudget]
+	ServiceDb     store.Store[*service.Service]
+	RevisionDb    store.Store[*service.Revision]
+	JobDb         store.Store[*job.Job]
+	CronJobDb     store.Store[*job.CronJob]
+	WorkflowDb    store.Store[*workflow.Workflow]
 	Workers       []string
 	WorkerNodes   []*node.Node
 	WorkerTaskMap map[string][]uuid.UUID
 	TaskWorkerMap map[uuid.UUID]string
 	LastWorker    int
 	Scheduler     scheduler.Scheduler
+
+	// Every RebalanceInterval, up to MaxMigrations tasks are moved off the
+	// busiest worker when its utilization is more than RebalanceSkew above
+	// that of the idlest one, or, before workers have reported their stats,
+	// when it runs more than RebalanceThreshold more tasks. A moved task keeps
+	// running until its copy is ready, for at most MigrationTimeout.
+	RebalanceInterval  time.Duration
+	RebalanceThreshold int
+	RebalanceSkew      float64
+	MaxMigrations      int
+	MigrationTimeout   time.Duration
+
+	// WorkerLastSeen holds when each worker last answered. Tasks on a worker
+	// that hasn't answered for WorkerTimeout are marked Lost, and fail once
+	// they have been lost for LostTaskTimeout, so that they are replaced.
+	WorkerLastSeen  map[string]time.Time
+	WorkerTimeout   time.Duration
+	LostTaskTimeout time.Duration
 }
 
 func (m *Manager) AddTask(te task.TaskEvent) {
+	if te.Task.SubmitTime.IsZero() {
+		te.Task.SubmitTime = time.Now().UTC()
+	}
 	m.Pending.Enqueue(te)
 }
 
+// submitTask records a new task as Pending and queues it for scheduling.
+func (m *Manager) submitTask(t task.Task) {
+	if t.SubmitTime.IsZero() {
+		t.SubmitTime = time.Now().UTC()
+	}
+	t.State = task.Pending
+	t.Transitions = nil
+	t.SetState(task.Pending, task.ReasonSubmitted, "")
+	m.TaskDb.Put(t.ID.String(), &t)
+
+	te := task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Running,
+		Timestamp: time.Now(),
+		Task:      t,
+	}
+	te.Task.State = task.Scheduled
+	m.AddTask(te)
+}
+
 func (m *Manager) SelectWorker(t task.Task) (*node.Node, error) {
 	candidates := m.Scheduler.SelectCandidateNodes(t, m.WorkerNodes)
 	if candidates == nil {
@@ -56,44 +113,120 @@ func (m *Manager) UpdateTasks() {
 	}
 }
 
+// updateTasks asks every worker for its tasks. The manager is only locked
+// while the answer of a worker is applied, not while waiting for it.
 func (m *Manager) updateTasks() {
 	for _, w := range m.Workers {
 		log.Printf("Checking worker %v for task updates", w)
 
 		client := client.New(w, "worker")
 		tasks, err := client.GetTasks()
+
+		m.mu.Lock()
 		if err != nil {
 			log.Printf("Error retrieving tasks from worker %s: %v\n", w, err)
+			m.markLostTasks(w)
+		} else {
+			m.updateWorkerTasks(w, tasks)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// updateWorkerTasks applies the tasks reported by a worker to their records.
+func (m *Manager) updateWorkerTasks(w string, tasks []*task.Task) {
+	m.WorkerLastSeen[w] = time.Now().UTC()
+
+	for _, t := range tasks {
+		log.Printf("Attempting to update task %v\n", t.ID)
+
+		// A task that has been moved keeps its record on the previous worker,
+		// only the worker currently running it is trusted.
+		if owner, ok := m.TaskWorkerMap[t.ID]; ok && owner != w {
 			continue
 		}
 
-		for _, t := range tasks {
-			log.Printf("Attempting to update task %v\n", t.ID)
+		persisted, err := m.TaskDb.Get(t.ID.String())
+		if err != nil {
+			log.Printf("Error retrieving task %s from DB: %v\n", t.ID, err)
+			continue
+		}
 
-			task, err := m.TaskDb.Get(t.ID.String())
-			if err != nil {
-				log.Printf("Error retrieving task %s from DB: %v\n", t.ID, err)
-				continue
-			}
+		// A task that ran out of restarts keeps the reason it was given up
+		// with, its worker has nothing new to report about it.
+		if persisted.Reason == task.ReasonRetriesExhausted {
+			continue
+		}
 
-			if task.State != t.State {
-				task.State = t.State
+		// A task given up as lost has been replaced by now, so its container
+		// is stopped if its worker comes back still running it.
+		if persisted.State == task.Failed && persisted.Reason == task.ReasonWorkerUnreachable {
+			if t.State != task.Completed && t.State != task.Failed {
+				m.stopTask(w, t.ID.String())
 			}
+			continue
+		}
 
-			task.StartTime = t.StartTime
-			task.FinishTime = t.FinishTime
-			task.ContainerID = t.ContainerID
-			task.HostPorts = t.HostPorts
+		// A restarted task may still be reported by the worker that ran it
+		// before, and that run started before the restart was due.
+		if !t.StartTime.IsZero() && t.StartTime.Before(persisted.NextRetryTime) {
+			continue
+		}
 
-			m.TaskDb.Put(t.ID.String(), task)
+		// The worker reports the transitions it made along with those the
+		// task came with. A task that was lost is back with whatever state
+		// its worker reports.
+		persisted.MergeTransitions(t.Transitions)
+		if persisted.State == task.Lost && t.State != task.Lost {
+			persisted.SetState(t.State, t.Reason, t.Message)
 		}
+		persisted.State = t.State
+		persisted.Reason = t.Reason
+		persisted.Message = t.Message
+
+		persisted.StartTime = t.StartTime
+		persisted.FinishTime = t.FinishTime
+		persisted.ContainerID = t.ContainerID
+		persisted.SidecarContainerIDs = t.SidecarContainerIDs
+		persisted.HostPorts = t.HostPorts
+		persisted.ExitCode = t.ExitCode
+		persisted.Outputs = t.Outputs
+		persisted.StartupStatus = t.StartupStatus
+		persisted.LivenessStatus = t.LivenessStatus
+		persisted.ReadinessStatus = t.ReadinessStatus
+		persisted.Ready = t.Ready
+
+		m.TaskDb.Put(t.ID.String(), persisted)
 	}
 }
 
+// SendWork sends the next task on the pending queue to a worker. The
+// manager is locked while the task is placed, but not while it is sent.
 func (m *Manager) SendWork() {
+	m.mu.Lock()
+	te, address, ok := m.nextWork()
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	client := client.New(address, "worker")
+	_, err := client.SendTask(te)
+	if err != nil {
+		log.Printf("Error sending task %s to worker %s: %v\n", te.Task.ID, address, err)
+		m.mu.Lock()
+		m.Pending.Enqueue(te)
+		m.mu.Unlock()
+	}
+}
+
+// nextWork takes the next event off the pending queue and handles it. It
+// returns the event and the address of the worker to send it to when a task
+// has been placed on a worker.
+func (m *Manager) nextWork() (task.TaskEvent, string, bool) {
 	if m.Pending.Len() == 0 {
 		log.Println("No work in the queue")
-		return
+		return task.TaskEvent{}, "", false
 	}
 
 	e := m.Pending.Dequeue()
@@ -106,39 +239,142 @@ func (m *Manager) SendWork() {
 		persistedTask, err := m.TaskDb.Get(te.Task.ID.String())
 		if err != nil {
 			log.Printf("Error occurred retrieving task %s from DB: %v\n", te.Task.ID, err)
-			return
+			return task.TaskEvent{}, "", false
 		}
 
 		if te.State == task.Completed && task.ValidStateTransition(persistedTask.State, te.State) {
 			m.stopTask(taskWorker, te.Task.ID.String())
-			return
+			return task.TaskEvent{}, "", false
 		}
 
 		log.Printf("invalid request: existing task %s is in state %v and cannon transition to the completed state\n", persistedTask.ID.String(), persistedTask.State)
-		return
+		return task.TaskEvent{}, "", false
+	}
+
+	if time.Now().UTC().Before(te.Task.NotBefore) {
+		log.Printf("Task %s is not due to start before %v, putting it back on the queue\n", te.Task.ID, te.Task.NotBefore)
+		m.Pending.Enqueue(te)
+		return task.TaskEvent{}, "", false
+	}
+
+	persistedTask, err := m.TaskDb.Get(te.Task.ID.String())
+	if err == nil && persistedTask.StopRequested {
+		log.Printf("Task %s was stopped before being scheduled, dropping it\n", te.Task.ID)
+		persistedTask.SetState(task.Completed, task.ReasonStopped, "stopped before being scheduled")
+		m.TaskDb.Put(persistedTask.ID.String(), persistedTask)
+		return task.TaskEvent{}, "", false
+	}
+
+	deadline, ok := te.Task.SchedulingDeadline()
+	if ok && time.Now().UTC().After(deadline) {
+		log.Printf("Task %s was not scheduled by %v, giving up\n", te.Task.ID, deadline)
+		m.failUnscheduledTask(te.Task, deadline)
+		return task.TaskEvent{}, "", false
+	}
+
+	if te.Task.ConcurrencyKey != "" {
+		limit := max(te.Task.ConcurrencyLimit, 1)
+		if m.concurrencyInUse(te.Task.ConcurrencyKey) >= limit {
+			log.Printf("Concurrency key %s already has %d tasks running, putting task %s back on the queue\n", te.Task.ConcurrencyKey, limit, te.Task.ID)
+			m.Pending.Enqueue(te)
+			return task.TaskEvent{}, "", false
+		}
 	}
 
 	t := te.Task
 	w, err := m.SelectWorker(t)
 	if err != nil {
 		log.Printf("error selecting worker for task %s: %v\n", t.ID, err)
-		return
+		m.Pending.Enqueue(te)
+		return task.TaskEvent{}, "", false
 	}
 
 	m.WorkerTaskMap[w.Name] = append(m.WorkerTaskMap[w.Name], te.Task.ID)
 	m.TaskWorkerMap[t.ID] = w.Name
 
-	t.State = task.Scheduled
+	// The event holds the state the task should reach, the history of the
+	// task is on its record.
+	if persistedTask != nil {
+		t.State = persistedTask.State
+		t.Reason = persistedTask.Reason
+		t.Message = persistedTask.Message
+		t.Transitions = persistedTask.Transitions
+	}
+	t.SetState(task.Scheduled, task.ReasonScheduled, fmt.Sprintf("assigned to worker %s", w.Name))
 	m.TaskDb.Put(t.ID.String(), &t)
+	te.Task = t
 
-	client := client.New(w.Ip, "worker")
-	_, err = client.SendTask(te)
-	if err != nil {
-		log.Printf("Error sending task %s to worker %s: %v\n", t.ID, w.Ip, err)
-		m.Pending.Enqueue(te)
+	return te, w.Ip, true
+}
+
+// failUnscheduledTask records that a task missed its scheduling deadline.
+func (m *Manager) failUnscheduledTask(t task.Task, deadline time.Time) {
+	persisted, err := m.TaskDb.Get(t.ID.String())
+	if err == nil {
+		t = *persisted
+	}
+
+	t.FinishTime = time.Now().UTC()
+	t.SetState(task.Failed, task.ReasonDeadlineExceeded, fmt.Sprintf("task was not scheduled within %ds", t.SchedulingDeadlineSeconds))
+	m.TaskDb.Put(t.ID.String(), &t)
+}
+
+// markLostTasks marks the tasks placed on a worker as Lost once the worker
+// hasn't answered for WorkerTimeout. They stay Lost until the worker reports
+// them again or they are stopped, for at most LostTaskTimeout, after which
+// they are given up as Failed and their restart policy or their service, job
+// or workflow replaces them.
+func (m *Manager) markLostTasks(w string) {
+	lastSeen := m.WorkerLastSeen[w]
+	if time.Since(lastSeen) < m.WorkerTimeout {
+		return
+	}
+
+	for _, id := range m.WorkerTaskMap[w] {
+		t, err := m.TaskDb.Get(id.String())
+		if err != nil {
+			continue
+		}
+
+		switch t.State {
+		case task.Scheduled, task.Pulling, task.Starting, task.Running, task.Paused, task.Stopping, task.Unknown:
+			log.Printf("Worker %s has not answered since %v, task %s is lost\n", w, lastSeen, t.ID)
+			t.Ready = false
+			t.SetState(task.Lost, task.ReasonWorkerUnreachable, fmt.Sprintf("worker %s has not answered since %s", w, lastSeen.Format(time.RFC3339)))
+			m.TaskDb.Put(t.ID.String(), t)
+
+		case task.Lost:
+			if time.Since(lastSeen) < m.WorkerTimeout+m.LostTaskTimeout {
+				continue
+			}
+			log.Printf("Worker %s has not answered since %v, giving up lost task %s\n", w, lastSeen, t.ID)
+			t.FinishTime = time.Now().UTC()
+			t.SetState(task.Failed, task.ReasonWorkerUnreachable, fmt.Sprintf("task was lost, worker %s has not answered since %s", w, lastSeen.Format(time.RFC3339)))
+			m.TaskDb.Put(t.ID.String(), t)
+		}
 	}
 }
 
+// concurrencyInUse counts the tasks holding a slot of the concurrency key:
+// those that have been sent to a worker and haven't finished yet, including
+// those still shutting down.
+func (m *Manager) concurrencyInUse(key string) int {
+	inUse := 0
+	for _, t := range m.GetTasks() {
+		if t.ConcurrencyKey != key {
+			continue
+		}
+
+		_, ok := m.TaskWorkerMap[t.ID]
+		if ok && (t.State == task.Scheduled || t.State == task.Pulling || t.State == task.Starting || t.State == task.Running ||
+			t.State == task.Paused || t.State == task.Stopping || t.State == task.Unknown || t.State == task.Lost) {
+			inUse++
+		}
+	}
+
+	return inUse
+}
+
 func (m *Manager) ProcessTasks() {
 	for {
 		log.Println("Processing any tasks in the queue")
@@ -148,6 +384,20 @@ func (m *Manager) ProcessTasks() {
 	}
 }
 
+// taskWorker returns the task and the worker it was placed on, if any. It
+// locks the manager itself, for handlers that don't hold the lock.
+func (m *Manager) taskWorker(id uuid.UUID) (*task.Task, string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.TaskDb.Get(id.String())
+	if err != nil {
+		return nil, "", false, err
+	}
+	w, ok := m.TaskWorkerMap[id]
+	return t, w, ok, nil
+}
+
 func (m *Manager) GetTasks() []*task.Task {
 	tasks, err := m.TaskDb.List()
 	if err != nil {
@@ -157,48 +407,17 @@ func (m *Manager) GetTasks() []*task.Task {
 	return tasks
 }
 
-func (m *Manager) checkTaskHealth(t task.Task) error {
-	log.Printf("Calling health check for task %s: %s\n", t.ID, t.HealthCheck)
-
-	w := m.TaskWorkerMap[t.ID]
-	hostPort := getHostPort(t.HostPorts)
-	if hostPort == nil {
-		log.Println("No port known for task %s, skipping", t.ID)
-		return nil
-	}
-
-	worker := strings.Split(w, ":")
-	url := fmt.Sprintf("http://%s:%s%s", worker[0], *hostPort, t.HealthCheck)
-	log.Printf("Calling health check for task %sL %s\n", t.ID, url)
-	resp, err := http.Get(url)
-	if err != nil {
-		msg := fmt.Sprintf("Error connecting to health check %s", url)
-		log.Println(msg)
-		return errors.New(msg)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		msg := fmt.Sprintf("Error health check for task %s did not return 200\n", t.ID)
-		log.Println(msg)
-		return errors.New(msg)
-	}
-
-	log.Printf("Task %s health check response: %v\n", t.ID, resp.StatusCode)
-
-	return nil
-}
-
 func (m *Manager) doHeathChecks() {
 	for _, t := range m.GetTasks() {
-		if t.State == task.Running {
-			err := m.checkTaskHealth(*t)
-			if err != nil {
-				if t.RestartCount < 3 {
-					m.restartTask(t)
-				}
-			}
-		} else if t.State == task.Failed && t.RestartCount < 3 {
-			m.restartTask(t)
+		if t.StopRequested {
+			continue
+		}
+
+		// Tasks failing their probes are stopped and marked Failed by their
+		// worker. Failed service, job and workflow tasks are handled by their
+		// reconcilers.
+		if t.State == task.Failed && t.Service == "" && t.Job == "" && t.Workflow == "" {
+			m.restartFailedTask(t)
 		}
 	}
 }
@@ -206,30 +425,12 @@ func (m *Manager) doHeathChecks() {
 func (m *Manager) DoHeathChecks() {
 	for {
 		log.Println("Performing task health check")
+		m.mu.Lock()
 		m.doHeathChecks()
+		m.mu.Unlock()
 		log.Println("Task health checks completed")
-		log.Println("Sleeping for 60 seconds")
-		time.Sleep(60 * time.Second)
-	}
-}
-
-func (m *Manager) restartTask(t *task.Task) {
-	w := m.TaskWorkerMap[t.ID]
-	t.State = task.Scheduled
-	t.RestartCount++
-	m.TaskDb.Put(t.ID.String(), t)
-
-	te := task.TaskEvent{
-		ID:        uuid.New(),
-		State:     task.Running,
-		Timestamp: time.Now(),
-		Task:      *t,
-	}
-	client := client.New(w, "worker")
-	_, err := client.SendTask(te)
-	if err != nil {
-		log.Printf("Error sending task %s to worker %s: %v\n", t.ID, w, err)
-		m.Pending.Enqueue(te)
+		log.Println("Sleeping for 5 seconds")
+		time.Sleep(5 * time.Second)
 	}
 }
 
@@ -250,25 +451,39 @@ func (m *Manager) CollectStats() {
 	}
 }
 
+// collectStats asks every worker for its stats, and only locks the manager
+// to record them.
 func (m *Manager) collectStats() {
 	for _, node := range m.WorkerNodes {
-		_, err := node.GetStats()
+		stats, err := node.FetchStats()
 		if err != nil {
 			log.Printf("Error retrieving stats for node %s: %v\n", node.Name, err)
 			continue
 		}
+
+		m.mu.Lock()
+		node.SetStats(*stats)
+		m.mu.Unlock()
 	}
 }
 
 func New(workers []string, schedulerType string, dbType string) *Manager {
 	taskDb := store.NewOfType[*task.Task](dbType, "tasks")
 	eventDb := store.NewOfType[*task.TaskEvent](dbType, "task_events")
+	budgetDb := store.NewOfType[*budget.DisruptionBudget](dbType, "budgets")
+	serviceDb := store.NewOfType[*service.Service](dbType, "services")
+	revisionDb := store.NewOfType[*service.Revision](dbType, "service_revisions")
+	jobDb := store.NewOfType[*job.Job](dbType, "jobs")
+	cronJobDb := store.NewOfType[*job.CronJob](dbType, "cron_jobs")
+	workflowDb := store.NewOfType[*workflow.Workflow](dbType, "workflows")
 	workerTaskMap := make(map[string][]uuid.UUID)
 	taskWorkerMap := make(map[uuid.UUID]string)
+	workerLastSeen := make(map[string]time.Time)
 
 	nodes := []*node.Node{}
 	for worker := range workers {
 		workerTaskMap[workers[worker]] = []uuid.UUID{}
+		workerLastSeen[workers[worker]] = time.Now().UTC()
 
 		nAPI := fmt.Sprintf("http://%v", workers[worker])
 		n := node.New(workers[worker], nAPI, "worker")
@@ -281,17 +496,41 @@ func New(workers []string, schedulerType string, dbType string) *Manager {
 		WorkerNodes:   nodes,
 		TaskDb:        taskDb,
 		EventDb:       eventDb,
+		BudgetDb:      budgetDb,
+		ServiceDb:     serviceDb,
+		RevisionDb:    revisionDb,
+		JobDb:         jobDb,
+		CronJobDb:     cronJobDb,
+		WorkflowDb:    workflowDb,
 		WorkerTaskMap: workerTaskMap,
 		TaskWorkerMap: taskWorkerMap,
 		LastWorker:    0,
 		Scheduler:     scheduler.NewOfType(schedulerType),
+
+		RebalanceInterval:  5 * time.Minute,
+		RebalanceThreshold: 1,
+		RebalanceSkew:      0.2,
+		MaxMigrations:      1,
+		MigrationTimeout:   5 * time.Minute,
+
+		WorkerLastSeen:  workerLastSeen,
+		WorkerTimeout:   time.Minute,
+		LostTaskTimeout: 5 * time.Minute,
 	}
 }
 
-func getHostPort(ports nat.PortMap) *string {
-	for k := range ports {
-		return &ports[k][0].HostPort
+// isActive reports whether the task is running or on its way to running,
+// which includes waiting to be restarted, and hasn't been asked to stop.
+// Lost tasks count as active, since their worker may still be running them.
+func isActive(t *task.Task) bool {
+	if t.StopRequested {
+		return false
+	}
+
+	switch t.State {
+	case task.Pending, task.Scheduled, task.Pulling, task.Starting, task.Running, task.Paused, task.CrashLoopBackOff, task.Unknown, task.Lost:
+		return true
 	}
 
-	return nil
+	return false
 }
@@ -0,0 +1,125 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/client"
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/google/uuid"
+)
+
+// restartFailedTask restarts a failed task if its restart policy allows it,
+// after waiting in CrashLoopBackOff for the backoff of the policy.
+func (m *Manager) restartFailedTask(t *task.Task) {
+	policy := t.RestartPolicy.WithDefaults()
+	if !policy.Restarts(t) {
+		return
+	}
+
+	m.scheduleRestart(t, policy)
+}
+
+// scheduleRestart puts a failed task back on the pending queue so that the
+// scheduler can place it again, possibly on a different worker. Restarts
+// that have to wait for their backoff leave the task in CrashLoopBackOff
+// until NextRetryTime.
+func (m *Manager) scheduleRestart(t *task.Task, policy task.RestartPolicy) {
+	if t.FinishTime.Sub(t.StartTime) >= policy.ResetAfter() {
+		t.RestartCount = 0
+	}
+
+	// A task out of retries is marked so once, and its policy no longer
+	// restarts it.
+	if t.RestartCount >= *policy.MaxRetries {
+		message := fmt.Sprintf("restarted %d times, not restarting again", t.RestartCount)
+		if t.Message != "" {
+			message = fmt.Sprintf("%s: %s", message, t.Message)
+		}
+		t.SetState(task.Failed, task.ReasonRetriesExhausted, message)
+		m.TaskDb.Put(t.ID.String(), t)
+		log.Printf("Task %s has been restarted %d times, not restarting it again\n", t.ID, t.RestartCount)
+		return
+	}
+
+	finishTime := t.FinishTime
+	if finishTime.IsZero() {
+		finishTime = time.Now().UTC()
+	}
+	backoff := policy.Backoff(t.RestartCount)
+	t.NextRetryTime = finishTime.Add(backoff)
+	t.RestartCount++
+	t.ResetProbes()
+	// The restart may be placed on another worker, so the failed run is
+	// removed from this one.
+	m.removeFromWorker(t)
+	m.unassignTask(t)
+
+	next := *t
+	next.State = task.Scheduled
+	next.NotBefore = t.NextRetryTime
+	next.ContainerID = ""
+	next.SidecarContainerIDs = nil
+	next.HostPorts = nil
+	next.StartTime = time.Time{}
+	next.FinishTime = time.Time{}
+	next.ExitCode = 0
+	next.Reason = ""
+	next.Message = ""
+	next.Outputs = nil
+	next.ResetProbes()
+
+	// The task leaves the Failed state right away, so that it isn't restarted
+	// again while it waits on the queue.
+	if time.Now().UTC().Before(t.NextRetryTime) {
+		message := fmt.Sprintf("back-off %v restarting failed task", backoff)
+		if t.Message != "" {
+			message = fmt.Sprintf("%s: %s", message, t.Message)
+		}
+		t.SetState(task.CrashLoopBackOff, task.ReasonCrashLoopBackOff, message)
+	} else {
+		t.SetState(task.Pending, task.ReasonRestarting, fmt.Sprintf("restart %d of failed task", t.RestartCount))
+	}
+	m.TaskDb.Put(t.ID.String(), t)
+
+	te := task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Running,
+		Timestamp: time.Now(),
+		Task:      next,
+	}
+	m.AddTask(te)
+	log.Printf("Restarting task %s at %v, restart %d\n", t.ID, t.NextRetryTime, t.RestartCount)
+}
+
+// removeFromWorker has the worker that ran a finished task forget it and
+// remove its containers.
+func (m *Manager) removeFromWorker(t *task.Task) {
+	w, ok := m.TaskWorkerMap[t.ID]
+	if !ok {
+		return
+	}
+
+	err := client.New(w, "worker").RemoveTask(t.ID.String())
+	if err != nil {
+		log.Printf("Error removing task %s from worker %s: %v\n", t.ID, w, err)
+	}
+}
+
+// unassignTask forgets the worker a task was placed on, so that the task can
+// be scheduled again.
+func (m *Manager) unassignTask(t *task.Task) {
+	w, ok := m.TaskWorkerMap[t.ID]
+	if !ok {
+		return
+	}
+
+	delete(m.TaskWorkerMap, t.ID)
+	for i, id := range m.WorkerTaskMap[w] {
+		if id == t.ID {
+			m.WorkerTaskMap[w] = append(m.WorkerTaskMap[w][:i], m.WorkerTaskMap[w][i+1:]...)
+			break
+		}
+	}
+}
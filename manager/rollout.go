@@ -0,0 +1,309 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/service"
+	"github.com/d-bolshakov/orchestrator/task"
+)
+
+// rollOut moves a service one step closer to running only tasks of its
+// current revision, following the update strategy of the service. Whatever
+// the strategy, the update is rolled back once too many new tasks fail.
+func (m *Manager) rollOut(s *service.Service, current []*task.Task, outdated []*task.Task) {
+	cfg := s.UpdateConfig.WithDefaults()
+
+	failures := m.countRevisionFailures(s.Name, s.Revision)
+	s.UpdateStatus.Failures = failures
+	if failures >= cfg.FailureThreshold {
+		if s.UpdateStatus.State != service.UpdateRollingBack && s.PreviousRevision != 0 {
+			reason := fmt.Sprintf("%d tasks of revision %d failed", failures, s.Revision)
+			err := m.rollBack(s, s.PreviousRevision, "", reason)
+			if err == nil {
+				return
+			}
+			log.Printf("Error rolling back service %s: %v\n", s.Name, err)
+		}
+
+		s.UpdateStatus.Message = fmt.Sprintf("%d tasks of revision %d failed", failures, s.Revision)
+	}
+
+	switch s.UpdateStatus.State {
+	case service.UpdateCanary:
+		m.rollOutCanary(s, cfg, current, outdated)
+	case service.UpdateBlueGreen:
+		m.rollOutBlueGreen(s, cfg, current, outdated)
+	default:
+		m.rollOutRolling(s, cfg, current, outdated)
+	}
+}
+
+// rollOutRolling stops outdated tasks as long as enough healthy tasks remain,
+// and starts new tasks in batches limited by MaxSurge. The next batch is only
+// started once every new task has passed its health check.
+func (m *Manager) rollOutRolling(s *service.Service, cfg service.UpdateConfig, current []*task.Task, outdated []*task.Task) {
+	healthy := 0
+	for _, t := range current {
+		if m.isTaskHealthy(t) {
+			healthy++
+		}
+	}
+	inFlight := len(current) - healthy
+
+	available := healthy
+	for _, t := range outdated {
+		if m.isTaskHealthy(t) {
+			available++
+		}
+	}
+
+	sortForRemoval(outdated)
+	minAvailable := s.Replicas - cfg.MaxUnavailable
+	remaining := len(outdated)
+	for _, t := range outdated {
+		if m.isTaskHealthy(t) {
+			if available-1 < minAvailable {
+				break
+			}
+
+			err := m.checkDisruptionBudgets(t)
+			if err != nil {
+				log.Printf("Not replacing task %s of service %s yet: %v\n", t.ID, s.Name, err)
+				break
+			}
+			available--
+		}
+
+		log.Printf("Stopping task %s of service %s, revision %d\n", t.ID, s.Name, t.Revision)
+		m.requestStop(t)
+		remaining--
+	}
+
+	if inFlight == 0 {
+		total := len(current) + remaining
+		for started := len(current); started < s.Replicas && total < s.Replicas+cfg.MaxSurge; started++ {
+			m.startServiceTask(s)
+			total++
+		}
+	}
+
+	if remaining == 0 && healthy >= s.Replicas {
+		if s.UpdateStatus.State == service.UpdateRollingBack {
+			s.UpdateStatus.State = service.UpdateRolledBack
+		} else {
+			s.UpdateStatus.State = service.UpdateCompleted
+		}
+		s.UpdateStatus.CompletedAt = time.Now().UTC()
+		s.ActiveRevision = s.Revision
+		log.Printf("Service %s is running revision %d\n", s.Name, s.Revision)
+	}
+}
+
+// rollOutCanary runs CanaryReplicas tasks of the new revision next to the full
+// set of the previous one and, with AutoPromote, judges the canary by the
+// share of its health checks that passed.
+func (m *Manager) rollOutCanary(s *service.Service, cfg service.UpdateConfig, current []*task.Task, outdated []*task.Task) {
+	m.keepRevision(s, s.PreviousRevision, outdated, s.Replicas)
+
+	for i := len(current); i < cfg.CanaryReplicas; i++ {
+		m.startServiceTask(s)
+	}
+
+	for _, t := range current {
+		if t.State != task.Running {
+			continue
+		}
+
+		s.UpdateStatus.Checks++
+		if m.isTaskHealthy(t) {
+			s.UpdateStatus.ChecksPassed++
+		}
+	}
+
+	if !cfg.AutoPromote || s.UpdateStatus.Checks < cfg.CanaryChecks {
+		s.UpdateStatus.Message = fmt.Sprintf("canary passed %d of %d health checks", s.UpdateStatus.ChecksPassed, s.UpdateStatus.Checks)
+		return
+	}
+
+	rate := float64(s.UpdateStatus.ChecksPassed) / float64(s.UpdateStatus.Checks)
+	if rate >= cfg.CanarySuccessRate {
+		m.promote(s, fmt.Sprintf("canary passed %.0f%% of health checks", rate*100))
+		return
+	}
+
+	reason := fmt.Sprintf("canary passed only %.0f%% of health checks", rate*100)
+	err := m.rollBack(s, s.PreviousRevision, "", reason)
+	if err != nil {
+		log.Printf("Error aborting canary of service %s: %v\n", s.Name, err)
+	}
+}
+
+// rollOutBlueGreen starts a full set of tasks of the new revision next to the
+// old set. Once all of them are healthy the service waits for promotion, or
+// switches to the new revision itself with AutoPromote.
+func (m *Manager) rollOutBlueGreen(s *service.Service, cfg service.UpdateConfig, current []*task.Task, outdated []*task.Task) {
+	m.keepRevision(s, s.PreviousRevision, outdated, s.Replicas)
+
+	for i := len(current); i < s.Replicas; i++ {
+		m.startServiceTask(s)
+	}
+
+	healthy := 0
+	for _, t := range current {
+		if m.isTaskHealthy(t) {
+			healthy++
+		}
+	}
+
+	if healthy < s.Replicas {
+		s.UpdateStatus.Message = fmt.Sprintf("%d of %d new tasks are healthy", healthy, s.Replicas)
+		return
+	}
+
+	if !cfg.AutoPromote {
+		s.UpdateStatus.Message = "new tasks are healthy, waiting for promotion"
+		return
+	}
+
+	m.promote(s, "all new tasks are healthy")
+}
+
+// keepRevision makes sure that the given number of tasks of an older revision
+// keeps running while the new revision is being evaluated.
+func (m *Manager) keepRevision(s *service.Service, revision int, tasks []*task.Task, replicas int) {
+	if len(tasks) >= replicas {
+		return
+	}
+
+	r, err := m.RevisionDb.Get(service.RevisionKey(s.Name, revision))
+	if err != nil {
+		log.Printf("Error retrieving revision %d of service %s: %v\n", revision, s.Name, err)
+		return
+	}
+
+	for i := len(tasks); i < replicas; i++ {
+		m.startRevisionTask(s, revision, r.Template)
+	}
+}
+
+// promote ends the evaluation of a canary or blue/green update. A canary
+// continues as a rolling update, a blue/green update switches the active
+// revision and lets the old set be stopped.
+func (m *Manager) promote(s *service.Service, reason string) {
+	if s.UpdateStatus.State == service.UpdateBlueGreen {
+		s.ActiveRevision = s.Revision
+	}
+
+	s.UpdateStatus.State = service.UpdateInProgress
+	s.UpdateStatus.Message = fmt.Sprintf("promoted: %s", reason)
+	log.Printf("Promoted revision %d of service %s: %s\n", s.Revision, s.Name, reason)
+}
+
+// PromoteService promotes a canary or blue/green update on request.
+func (m *Manager) PromoteService(name string) error {
+	s, err := m.ServiceDb.Get(name)
+	if err != nil {
+		return err
+	}
+
+	if s.UpdateStatus.State != service.UpdateCanary && s.UpdateStatus.State != service.UpdateBlueGreen {
+		return fmt.Errorf("service %s has no update waiting for promotion", name)
+	}
+
+	m.promote(s, "requested by user")
+	return m.ServiceDb.Put(name, s)
+}
+
+// AbortService rolls back an update that is still in progress.
+func (m *Manager) AbortService(name string) error {
+	s, err := m.ServiceDb.Get(name)
+	if err != nil {
+		return err
+	}
+
+	if !s.Updating() || s.UpdateStatus.State == service.UpdateRollingBack {
+		return fmt.Errorf("service %s has no update in progress", name)
+	}
+
+	err = m.rollBack(s, s.PreviousRevision, "", "aborted by user")
+	if err != nil {
+		return err
+	}
+	return m.ServiceDb.Put(name, s)
+}
+
+// rollBack switches the service to the template of an earlier revision by
+// recording it as a new revision. Live tasks of the earlier revision run the
+// same template, so they are moved to the new revision instead of replaced.
+func (m *Manager) rollBack(s *service.Service, revision int, author string, reason string) error {
+	r, err := m.RevisionDb.Get(service.RevisionKey(s.Name, revision))
+	if err != nil {
+		return fmt.Errorf("revision %d of service %s not found", revision, s.Name)
+	}
+
+	log.Printf("Rolling back service %s from revision %d to %d\n", s.Name, s.Revision, revision)
+
+	s.PreviousRevision = s.Revision
+	s.Revision++
+	s.Template = r.Template
+	s.Author = author
+	s.ChangeCause = fmt.Sprintf("rollback to revision %d", revision)
+	if reason != "" {
+		s.ChangeCause = fmt.Sprintf("%s: %s", s.ChangeCause, reason)
+	}
+	s.UpdateStatus = service.UpdateStatus{
+		State:     service.UpdateRollingBack,
+		StartedAt: time.Now().UTC(),
+		Message:   reason,
+	}
+
+	err = m.recordRevision(s)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range m.serviceTasks(s.Name) {
+		if t.Revision == revision {
+			t.Revision = s.Revision
+			m.TaskDb.Put(t.ID.String(), t)
+		}
+	}
+
+	return nil
+}
+
+// countRevisionFailures counts the tasks of a service revision that failed,
+// including those stopped by a failed probe, or had to be restarted.
+func (m *Manager) countRevisionFailures(name string, revision int) int {
+	failures := 0
+	for _, t := range m.GetTasks() {
+		if t.Service != name || t.Revision != revision {
+			continue
+		}
+
+		if t.State == task.Failed || t.RestartCount > 0 {
+			failures++
+		}
+	}
+
+	return failures
+}
+
+func (m *Manager) isTaskHealthy(t *task.Task) bool {
+	return t.State == task.Running && t.Ready
+}
+
+func splitByRevision(tasks []*task.Task, revision int) ([]*task.Task, []*task.Task) {
+	current, outdated := []*task.Task{}, []*task.Task{}
+	for _, t := range tasks {
+		if t.Revision == revision {
+			current = append(current, t)
+		} else {
+			outdated = append(outdated, t)
+		}
+	}
+
+	return current, outdated
+}
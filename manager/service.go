@@ -0,0 +1,308 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/service"
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/google/uuid"
+)
+
+func (m *Manager) CreateService(s *service.Service) error {
+	_, err := m.ServiceDb.Get(s.Name)
+	if err == nil {
+		return fmt.Errorf("service %s already exists", s.Name)
+	}
+
+	s.ReadyReplicas = 0
+	s.Revision = 1
+	s.PreviousRevision = 0
+	s.ActiveRevision = 1
+	s.UpdateStatus = service.UpdateStatus{}
+
+	err = m.recordRevision(s)
+	if err != nil {
+		return err
+	}
+	return m.ServiceDb.Put(s.Name, s)
+}
+
+// UpdateService applies a new specification to an existing service. A changed
+// task template starts a rolling update to a new revision.
+func (m *Manager) UpdateService(name string, spec *service.Service) error {
+	s, err := m.ServiceDb.Get(name)
+	if err != nil {
+		return err
+	}
+
+	s.Replicas = spec.Replicas
+	s.UpdateConfig = spec.UpdateConfig
+
+	if !reflect.DeepEqual(s.Template, spec.Template) {
+		s.PreviousRevision = s.Revision
+		s.Revision++
+		s.Template = spec.Template
+		s.Author = spec.Author
+		s.ChangeCause = spec.ChangeCause
+		s.UpdateStatus = service.UpdateStatus{
+			State:     s.UpdateConfig.UpdateState(),
+			StartedAt: time.Now().UTC(),
+		}
+
+		err = m.recordRevision(s)
+		if err != nil {
+			return err
+		}
+		log.Printf("Updating service %s to revision %d\n", name, s.Revision)
+	}
+
+	return m.ServiceDb.Put(name, s)
+}
+
+// RollbackService brings back the template of an earlier revision. The
+// rollback is recorded as a new revision, so the history stays in the order
+// in which the templates were deployed.
+func (m *Manager) RollbackService(name string, revision int, author string) error {
+	s, err := m.ServiceDb.Get(name)
+	if err != nil {
+		return err
+	}
+
+	if revision == 0 {
+		revision = s.PreviousRevision
+	}
+	if revision == s.Revision {
+		return fmt.Errorf("service %s is already at revision %d", name, revision)
+	}
+
+	err = m.rollBack(s, revision, author, "")
+	if err != nil {
+		return err
+	}
+	return m.ServiceDb.Put(name, s)
+}
+
+func (m *Manager) GetServiceHistory(name string) ([]*service.Revision, error) {
+	_, err := m.ServiceDb.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := m.RevisionDb.List()
+	if err != nil {
+		return nil, err
+	}
+
+	history := []*service.Revision{}
+	for _, r := range revisions {
+		if r.Service == name {
+			history = append(history, r)
+		}
+	}
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Number < history[j].Number
+	})
+
+	return history, nil
+}
+
+func (m *Manager) recordRevision(s *service.Service) error {
+	r := service.Revision{
+		Service:     s.Name,
+		Number:      s.Revision,
+		Template:    s.Template,
+		Timestamp:   time.Now().UTC(),
+		Author:      s.Author,
+		ChangeCause: s.ChangeCause,
+	}
+
+	return m.RevisionDb.Put(service.RevisionKey(s.Name, r.Number), &r)
+}
+
+func (m *Manager) GetServices() []*service.Service {
+	services, err := m.ServiceDb.List()
+	if err != nil {
+		log.Printf("Error getting list of services: %v\n", err)
+		return nil
+	}
+	return services
+}
+
+func (m *Manager) ScaleService(name string, replicas int) error {
+	if replicas < 0 {
+		return fmt.Errorf("service %s cannot have a negative number of replicas", name)
+	}
+
+	s, err := m.ServiceDb.Get(name)
+	if err != nil {
+		return err
+	}
+
+	s.Replicas = replicas
+	return m.ServiceDb.Put(name, s)
+}
+
+// DeleteService removes the service and stops all of its tasks. Unless force
+// is set, it refuses to if stopping them would violate a disruption budget.
+func (m *Manager) DeleteService(name string, force bool) error {
+	_, err := m.ServiceDb.Get(name)
+	if err != nil {
+		return err
+	}
+
+	tasks := m.serviceTasks(name)
+	if !force {
+		err = m.checkDisruptionBudgets(tasks...)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, t := range tasks {
+		m.requestStop(t)
+	}
+
+	history, err := m.GetServiceHistory(name)
+	if err != nil {
+		return err
+	}
+	for _, r := range history {
+		m.RevisionDb.Delete(service.RevisionKey(name, r.Number))
+	}
+
+	return m.ServiceDb.Delete(name)
+}
+
+func (m *Manager) ReconcileServices() {
+	for {
+		log.Println("Reconciling services")
+		m.mu.Lock()
+		m.reconcileServices()
+		m.mu.Unlock()
+		log.Println("Service reconciliation completed")
+		log.Println("Sleeping for 15 seconds")
+		time.Sleep(15 * time.Second)
+	}
+}
+
+func (m *Manager) reconcileServices() {
+	for _, s := range m.GetServices() {
+		m.reconcileService(s)
+	}
+}
+
+// reconcileService creates or stops tasks until the number of live tasks of
+// the service matches the desired replica count. Failed tasks are not counted
+// and are replaced with new ones. While an update is in progress the rollout
+// decides which tasks to start and stop.
+func (m *Manager) reconcileService(s *service.Service) {
+	active := m.serviceTasks(s.Name)
+	defer m.ServiceDb.Put(s.Name, s)
+
+	ready := 0
+	for _, t := range active {
+		if m.isTaskHealthy(t) {
+			ready++
+		}
+	}
+	s.ReadyReplicas = ready
+
+	if s.Updating() {
+		current, outdated := splitByRevision(active, s.Revision)
+		m.rollOut(s, current, outdated)
+		return
+	}
+
+	diff := s.Replicas - len(active)
+	if diff > 0 {
+		log.Printf("Service %s has %d of %d replicas, starting %d\n", s.Name, len(active), s.Replicas, diff)
+		for i := 0; i < diff; i++ {
+			m.startServiceTask(s)
+		}
+		return
+	}
+
+	if diff < 0 {
+		log.Printf("Service %s has %d of %d replicas, stopping %d\n", s.Name, len(active), s.Replicas, -diff)
+		sortForRemoval(active)
+		for _, t := range active[:-diff] {
+			err := m.checkDisruptionBudgets(t)
+			if err != nil {
+				log.Printf("Not stopping task %s of service %s yet: %v\n", t.ID, s.Name, err)
+				continue
+			}
+			m.requestStop(t)
+		}
+	}
+}
+
+// sortForRemoval orders tasks so that the ones that are cheapest to get rid of
+// come first: tasks that are not running and ready yet, then the most recently
+// started.
+func sortForRemoval(tasks []*task.Task) {
+	sort.Slice(tasks, func(i, j int) bool {
+		ri, rj := tasks[i].State == task.Running && tasks[i].Ready, tasks[j].State == task.Running && tasks[j].Ready
+		if ri != rj {
+			return rj
+		}
+		return tasks[i].StartTime.After(tasks[j].StartTime)
+	})
+}
+
+// serviceTasks returns the tasks of the service that are running or on their
+// way to running and haven't been asked to stop. Copies of tasks being moved
+// to another worker are left out, they stand for the task they replace.
+func (m *Manager) serviceTasks(name string) []*task.Task {
+	tasks := []*task.Task{}
+	for _, t := range m.GetTasks() {
+		if t.Service == name && isActive(t) && t.MigratedFrom == uuid.Nil {
+			tasks = append(tasks, t)
+		}
+	}
+
+	return tasks
+}
+
+func (m *Manager) startServiceTask(s *service.Service) {
+	m.startRevisionTask(s, s.Revision, s.Template)
+}
+
+// startRevisionTask starts a task of the service from the template of the
+// given revision, which doesn't have to be the current one.
+func (m *Manager) startRevisionTask(s *service.Service, revision int, template task.Task) {
+	t := template
+	t.ID = uuid.New()
+	t.Name = fmt.Sprintf("%s-%s", s.Name, t.ID.String()[:8])
+	t.Service = s.Name
+	t.Revision = revision
+	t.ContainerID = ""
+	t.HostPorts = nil
+	t.RestartCount = 0
+	t.StopRequested = false
+
+	t.Labels = map[string]string{}
+	for k, v := range template.Labels {
+		t.Labels[k] = v
+	}
+	t.Labels["service"] = s.Name
+
+	m.submitTask(t)
+}
+
+// requestStop marks the task as going away, so it is no longer counted as a
+// replica, and asks its worker to stop it. Tasks that haven't been sent to a
+// worker yet are dropped when they come off the pending queue.
+func (m *Manager) requestStop(t *task.Task) {
+	t.StopRequested = true
+	m.TaskDb.Put(t.ID.String(), t)
+
+	w, ok := m.TaskWorkerMap[t.ID]
+	if !ok {
+		return
+	}
+	m.stopTask(w, t.ID.String())
+}
@@ -0,0 +1,204 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/d-bolshakov/orchestrator/workflow"
+	"github.com/google/uuid"
+)
+
+func (m *Manager) CreateWorkflow(w *workflow.Workflow) error {
+	_, err := m.WorkflowDb.Get(w.Name)
+	if err == nil {
+		return fmt.Errorf("workflow %s already exists", w.Name)
+	}
+
+	w.State = workflow.Running
+	w.StartTime = time.Now().UTC()
+	w.CompletionTime = time.Time{}
+	w.Message = ""
+	for i := range w.Steps {
+		w.Steps[i].State = workflow.StepWaiting
+		w.Steps[i].TaskID = uuid.Nil
+		w.Steps[i].Outputs = nil
+		w.Steps[i].Message = ""
+	}
+
+	return m.WorkflowDb.Put(w.Name, w)
+}
+
+func (m *Manager) GetWorkflows() []*workflow.Workflow {
+	workflows, err := m.WorkflowDb.List()
+	if err != nil {
+		log.Printf("Error getting list of workflows: %v\n", err)
+		return nil
+	}
+	return workflows
+}
+
+// DeleteWorkflow removes the workflow and stops the tasks of its running
+// steps.
+func (m *Manager) DeleteWorkflow(name string) error {
+	w, err := m.WorkflowDb.Get(name)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range w.Steps {
+		if s.State != workflow.StepRunning {
+			continue
+		}
+
+		t, err := m.TaskDb.Get(s.TaskID.String())
+		if err == nil && isActive(t) {
+			m.requestStop(t)
+		}
+	}
+
+	return m.WorkflowDb.Delete(name)
+}
+
+func (m *Manager) ReconcileWorkflows() {
+	for {
+		log.Println("Reconciling workflows")
+		m.mu.Lock()
+		m.reconcileWorkflows()
+		m.mu.Unlock()
+		log.Println("Workflow reconciliation completed")
+		log.Println("Sleeping for 10 seconds")
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func (m *Manager) reconcileWorkflows() {
+	for _, w := range m.GetWorkflows() {
+		if w.Finished() {
+			continue
+		}
+		m.reconcileWorkflow(w)
+	}
+}
+
+// reconcileWorkflow records the outcome of finished step tasks and starts the
+// steps whose dependencies are now complete. Steps are visited repeatedly so
+// that skipped steps propagate through the whole graph in one pass.
+func (m *Manager) reconcileWorkflow(w *workflow.Workflow) {
+	defer m.WorkflowDb.Put(w.Name, w)
+
+	for i := range w.Steps {
+		s := &w.Steps[i]
+		if s.State == workflow.StepRunning {
+			m.updateStep(w, s)
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for i := range w.Steps {
+			s := &w.Steps[i]
+			if s.State == workflow.StepWaiting && m.advanceStep(w, s) {
+				changed = true
+			}
+		}
+	}
+
+	failed := []string{}
+	for _, s := range w.Steps {
+		if !s.Finished() {
+			return
+		}
+		if s.State == workflow.StepFailed {
+			failed = append(failed, s.Name)
+		}
+	}
+
+	w.CompletionTime = time.Now().UTC()
+	if len(failed) > 0 {
+		w.State = workflow.Failed
+		w.Message = fmt.Sprintf("steps failed: %s", strings.Join(failed, ", "))
+	} else {
+		w.State = workflow.Succeeded
+	}
+	log.Printf("Workflow %s %s\n", w.Name, w.State)
+}
+
+func (m *Manager) updateStep(w *workflow.Workflow, s *workflow.Step) {
+	t, err := m.TaskDb.Get(s.TaskID.String())
+	if err != nil {
+		log.Printf("Error retrieving task %s of step %s in workflow %s: %v\n", s.TaskID, s.Name, w.Name, err)
+		return
+	}
+
+	switch t.State {
+	case task.Completed:
+		if !hasSucceeded(t) {
+			s.State = workflow.StepFailed
+			s.Message = "task was stopped"
+			return
+		}
+		s.State = workflow.StepSucceeded
+		s.Outputs = t.Outputs
+
+	case task.Failed:
+		s.State = workflow.StepFailed
+		s.Message = t.Message
+	}
+}
+
+// advanceStep starts or skips a waiting step once all of its dependencies
+// have finished. It reports whether the step left the waiting state.
+func (m *Manager) advanceStep(w *workflow.Workflow, s *workflow.Step) bool {
+	env := []string{}
+	for _, d := range s.DependsOn {
+		dep := w.Step(d.Step)
+		if !dep.Finished() {
+			return false
+		}
+
+		if !d.Satisfied(dep) {
+			s.State = workflow.StepSkipped
+			s.Message = fmt.Sprintf("step %s %s", dep.Name, dep.State)
+			return true
+		}
+
+		for name, value := range dep.Outputs {
+			env = append(env, fmt.Sprintf("OUTPUT_%s_%s=%s", envName(dep.Name), envName(name), value))
+		}
+	}
+
+	t := s.Task
+	t.ID = uuid.New()
+	t.Name = fmt.Sprintf("%s-%s-%s", w.Name, s.Name, t.ID.String()[:8])
+	t.Kind = task.KindJob
+	t.Workflow = w.Name
+	t.Env = append(append([]string{}, s.Task.Env...), env...)
+	t.ContainerID = ""
+	t.HostPorts = nil
+	t.RestartCount = 0
+	t.StopRequested = false
+
+	t.Labels = map[string]string{}
+	for k, v := range s.Task.Labels {
+		t.Labels[k] = v
+	}
+	t.Labels["workflow"] = w.Name
+	t.Labels["step"] = s.Name
+
+	m.submitTask(t)
+	s.TaskID = t.ID
+	s.State = workflow.StepRunning
+	log.Printf("Started step %s of workflow %s as task %s\n", s.Name, w.Name, t.ID)
+
+	return true
+}
+
+var invalidEnvChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func envName(s string) string {
+	return strings.ToUpper(invalidEnvChars.ReplaceAllString(s, "_"))
+}
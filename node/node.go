@@ -7,11 +7,16 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/d-bolshakov/orchestrator/utils"
 	"github.com/d-bolshakov/orchestrator/worker"
 )
 
+// statsClient gives up on workers that don't answer, so that collecting
+// stats cannot hang.
+var statsClient = &http.Client{Timeout: 10 * time.Second}
+
 type Node struct {
 	Name            string
 	Ip              string
@@ -25,9 +30,22 @@ type Node struct {
 	Stats           worker.Stats
 }
 
+// GetStats fetches the stats of the node and records them on it.
 func (n *Node) GetStats() (*worker.Stats, error) {
+	stats, err := n.FetchStats()
+	if err != nil {
+		return nil, err
+	}
+
+	n.SetStats(*stats)
+	return &n.Stats, nil
+}
+
+// FetchStats asks the worker of the node for its stats, without recording
+// them on the node.
+func (n *Node) FetchStats() (*worker.Stats, error) {
 	url := fmt.Sprintf("%s/stats", n.Ip)
-	resp, err := utils.HTTPWithRetry(http.Get, url)
+	resp, err := utils.HTTPWithRetry(statsClient.Get, url)
 	if err != nil {
 		msg := fmt.Sprintf("Unable to connect to %v. Permanent failure.", n.Ip)
 		log.Println(msg)
@@ -50,12 +68,16 @@ func (n *Node) GetStats() (*worker.Stats, error) {
 		return nil, errors.New(msg)
 	}
 
+	return &stats, nil
+}
+
+// SetStats records stats fetched from the worker of the node.
+func (n *Node) SetStats(stats worker.Stats) {
 	n.Memory = int64(stats.MemTotalKb())
 	n.Disk = int64(stats.DiskTotal())
 
 	n.Stats = stats
 	n.TaskCount = stats.TaskCount
-	return &n.Stats, nil
 }
 
 func New(name string, address string, role string) *Node {
@@ -0,0 +1,137 @@
+package probe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Target is what a probe checks: Host and Port for network probes, the
+// container for exec probes.
+type Target struct {
+	Host        string
+	Port        string
+	ContainerID string
+}
+
+func (t Target) Address() string {
+	return net.JoinHostPort(t.Host, t.Port)
+}
+
+// Check runs the probe once against the target and returns why it failed.
+func (p Probe) Check(target Target) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout())
+	defer cancel()
+
+	switch p.Type {
+	case TypeHTTP:
+		return checkHTTP(ctx, p, target)
+	case TypeTCP:
+		return checkTCP(ctx, target)
+	case TypeExec:
+		return checkExec(ctx, p, target)
+	case TypeGRPC:
+		return checkGRPC(ctx, p, target)
+	}
+
+	return fmt.Errorf("unknown probe type %q", p.Type)
+}
+
+func checkHTTP(ctx context.Context, p Probe, target Target) error {
+	url := fmt.Sprintf("http://%s%s", target.Address(), p.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < p.MinStatus || resp.StatusCode > p.MaxStatus {
+		return fmt.Errorf("%s returned status %d, expected %d-%d", url, resp.StatusCode, p.MinStatus, p.MaxStatus)
+	}
+
+	return nil
+}
+
+func checkTCP(ctx context.Context, target Target) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", target.Address())
+	if err != nil {
+		return fmt.Errorf("error connecting to %s: %v", target.Address(), err)
+	}
+
+	return conn.Close()
+}
+
+func checkExec(ctx context.Context, p Probe, target Target) error {
+	dc, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return err
+	}
+	defer dc.Close()
+
+	exec, err := dc.ContainerExecCreate(ctx, target.ContainerID, container.ExecOptions{
+		Cmd:          p.Command,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating exec in container %s: %v", target.ContainerID, err)
+	}
+
+	resp, err := dc.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return fmt.Errorf("error running %v in container %s: %v", p.Command, target.ContainerID, err)
+	}
+	defer resp.Close()
+
+	var output bytes.Buffer
+	_, err = stdcopy.StdCopy(&output, &output, resp.Reader)
+	if err != nil {
+		return fmt.Errorf("error reading output of %v: %v", p.Command, err)
+	}
+
+	inspect, err := dc.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return err
+	}
+	if inspect.ExitCode != 0 {
+		return fmt.Errorf("%v exited with code %d: %s", p.Command, inspect.ExitCode, strings.TrimSpace(output.String()))
+	}
+
+	return nil
+}
+
+func checkGRPC(ctx context.Context, p Probe, target Target) error {
+	conn, err := grpc.NewClient(target.Address(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: p.Service})
+	if err != nil {
+		return fmt.Errorf("error calling health service at %s: %v", target.Address(), err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("health service at %s reported %s", target.Address(), resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package probe
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	TypeHTTP = "http"
+	TypeTCP  = "tcp"
+	TypeExec = "exec"
+	TypeGRPC = "grpc"
+)
+
+// Probe describes how to check the health of a task. HTTP probes expect a
+// status between MinStatus and MaxStatus from Path, TCP probes only need to
+// connect, exec probes run Command in the container and expect exit code 0,
+// and gRPC probes call the standard health service for Service.
+//
+// Port is the port of the container to probe, the first exposed port if
+// omitted. Probes start InitialDelaySeconds after the task has started and
+// run every IntervalSeconds, each one failing after TimeoutSeconds. A task
+// becomes unhealthy after FailureThreshold failed probes in a row and
+// healthy again after SuccessThreshold successful ones.
+type Probe struct {
+	Type                string
+	Port                int
+	Path                string
+	MinStatus           int
+	MaxStatus           int
+	Command             []string
+	Service             string
+	TimeoutSeconds      int
+	IntervalSeconds     int
+	FailureThreshold    int
+	SuccessThreshold    int
+	InitialDelaySeconds int
+}
+
+func (p *Probe) Validate() error {
+	switch p.Type {
+	case TypeHTTP, TypeTCP, TypeGRPC:
+	case TypeExec:
+		if len(p.Command) == 0 {
+			return fmt.Errorf("exec probe must have a command")
+		}
+	default:
+		return fmt.Errorf("unknown probe type %q", p.Type)
+	}
+
+	if p.Port < 0 || p.MinStatus < 0 || p.MaxStatus < 0 || p.TimeoutSeconds < 0 || p.IntervalSeconds < 0 || p.FailureThreshold < 0 || p.SuccessThreshold < 0 || p.InitialDelaySeconds < 0 {
+		return fmt.Errorf("%s probe cannot have negative values", p.Type)
+	}
+	// The range is checked as it will be used, a MinStatus of 400 alone
+	// makes it 400-399.
+	d := p.WithDefaults()
+	if d.MaxStatus < d.MinStatus {
+		return fmt.Errorf("%s probe has an empty status range %d-%d", p.Type, d.MinStatus, d.MaxStatus)
+	}
+
+	return nil
+}
+
+// WithDefaults returns a copy of the probe with zero values replaced by the
+// defaults: statuses 200-399, a 1 second timeout, a probe every 10 seconds,
+// 3 failures to become unhealthy and 1 success to become healthy.
+func (p Probe) WithDefaults() Probe {
+	if p.Path == "" {
+		p.Path = "/"
+	}
+	if p.MinStatus == 0 {
+		p.MinStatus = 200
+	}
+	if p.MaxStatus == 0 {
+		p.MaxStatus = 399
+	}
+	if p.TimeoutSeconds == 0 {
+		p.TimeoutSeconds = 1
+	}
+	if p.IntervalSeconds == 0 {
+		p.IntervalSeconds = 10
+	}
+	if p.FailureThreshold == 0 {
+		p.FailureThreshold = 3
+	}
+	if p.SuccessThreshold == 0 {
+		p.SuccessThreshold = 1
+	}
+
+	return p
+}
+
+func (p Probe) Timeout() time.Duration {
+	return time.Duration(p.TimeoutSeconds) * time.Second
+}
+
+func (p Probe) Interval() time.Duration {
+	return time.Duration(p.IntervalSeconds) * time.Second
+}
+
+func (p Probe) InitialDelay() time.Duration {
+	return time.Duration(p.InitialDelaySeconds) * time.Second
+}
+
+// MaxHistory is the number of probe results kept in the status of a task.
+const MaxHistory = 10
+
+// Status is the outcome of the probes run so far. A task is healthy once
+// SuccessThreshold probes in a row have passed, and stays healthy until
+// FailureThreshold probes in a row have failed. History holds the latest
+// results, oldest first.
+type Status struct {
+	Healthy              bool
+	ConsecutiveFailures  int
+	ConsecutiveSuccesses int
+	LastProbeTime        time.Time
+	LastError            string
+	History              []Result
+}
+
+type Result struct {
+	Time     time.Time
+	Duration time.Duration
+	Error    string
+}
+
+// Due reports whether the probe should run again, given when the task
+// started.
+func (s *Status) Due(p Probe, startTime time.Time, now time.Time) bool {
+	if now.Before(startTime.Add(p.InitialDelay())) {
+		return false
+	}
+
+	return now.Sub(s.LastProbeTime) >= p.Interval()
+}
+
+// Record updates the status with the result of a probe that started at now
+// and took the given time.
+func (s *Status) Record(p Probe, err error, now time.Time, took time.Duration) {
+	s.LastProbeTime = now
+
+	r := Result{Time: now, Duration: took}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	s.History = append(s.History, r)
+	if len(s.History) > MaxHistory {
+		s.History = s.History[len(s.History)-MaxHistory:]
+	}
+
+	if err != nil {
+		s.ConsecutiveFailures++
+		s.ConsecutiveSuccesses = 0
+		s.LastError = err.Error()
+		if s.Failed(p) {
+			s.Healthy = false
+		}
+		return
+	}
+
+	s.ConsecutiveSuccesses++
+	s.ConsecutiveFailures = 0
+	s.LastError = ""
+	if s.ConsecutiveSuccesses >= p.SuccessThreshold {
+		s.Healthy = true
+	}
+}
+
+// Failed reports whether enough probes have failed in a row to give up on
+// the task.
+func (s *Status) Failed(p Probe) bool {
+	return s.ConsecutiveFailures >= p.FailureThreshold
+}
@@ -0,0 +1,32 @@
+package probe
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		probe   Probe
+		wantErr bool
+	}{
+		{"http", Probe{Type: TypeHTTP}, false},
+		{"tcp", Probe{Type: TypeTCP, Port: 5432}, false},
+		{"grpc", Probe{Type: TypeGRPC}, false},
+		{"exec", Probe{Type: TypeExec, Command: []string{"true"}}, false},
+		{"exec without command", Probe{Type: TypeExec}, true},
+		{"unknown type", Probe{Type: "udp"}, true},
+		{"negative value", Probe{Type: TypeHTTP, TimeoutSeconds: -1}, true},
+		{"status range", Probe{Type: TypeHTTP, MinStatus: 200, MaxStatus: 204}, false},
+		{"single status", Probe{Type: TypeHTTP, MinStatus: 204, MaxStatus: 204}, false},
+		{"max status only", Probe{Type: TypeHTTP, MaxStatus: 299}, false},
+		{"min status above max", Probe{Type: TypeHTTP, MinStatus: 300, MaxStatus: 200}, true},
+		{"min status above default max", Probe{Type: TypeHTTP, MinStatus: 400}, true},
+		{"max status below default min", Probe{Type: TypeHTTP, MaxStatus: 100}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.probe.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
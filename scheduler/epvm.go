@@ -19,7 +19,7 @@ type Epvm struct {
 func (e *Epvm) SelectCandidateNodes(t task.Task, nodes []*node.Node) []*node.Node {
 	candidates := []*node.Node{}
 	for node := range nodes {
-		if hasEnoughDiskAvailable(nodes[node], t.Disk) {
+		if hasEnoughDiskAvailable(nodes[node], t.TotalDisk()) {
 			candidates = append(candidates, nodes[node])
 		}
 	}
@@ -45,7 +45,7 @@ func (e *Epvm) Score(t task.Task, nodes []*node.Node) map[string]float64 {
 		memoryAllocated := float64(stats.MemUsedKb()) + float64(node.MemoryAllocated)
 		memoryPercentAllocated := memoryAllocated / float64(node.Memory)
 
-		newMemPercent := (calculateLoad(memoryAllocated+float64(t.Memory/1000), float64(node.Memory)))
+		newMemPercent := (calculateLoad(memoryAllocated+float64(t.TotalMemory()/1000), float64(node.Memory)))
 
 		memCost := math.Pow(LIEB, newMemPercent) + math.Pow(LIEB,
 			(float64(node.TaskCount+1))/maxJobs) - math.Pow(LIEB, memoryPercentAllocated) -
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/task"
+)
+
+const (
+	UpdateInProgress  = "updating"
+	UpdateCanary      = "canary"
+	UpdateBlueGreen   = "blue_green"
+	UpdateCompleted   = "completed"
+	UpdateRollingBack = "rolling_back"
+	UpdateRolledBack  = "rolled_back"
+)
+
+const (
+	StrategyRolling   = "rolling"
+	StrategyCanary    = "canary"
+	StrategyBlueGreen = "bluegreen"
+)
+
+// Service keeps a number of identical tasks running. The manager creates the
+// tasks from the template and replaces them when they fail or disappear.
+// Only tasks that are ready count as available replicas.
+//
+// Every change of the template, including a rollback, creates a new revision
+// which is kept in the revision history. Tasks of older revisions are replaced
+// in batches, as described by the UpdateConfig. Author and ChangeCause
+// describe the latest change and are copied into its revision.
+type Service struct {
+	Name             string
+	Replicas         int
+	Template         task.Task
+	UpdateConfig     UpdateConfig
+	Author           string
+	ChangeCause      string
+	Revision         int
+	PreviousRevision int
+	ActiveRevision   int
+	UpdateStatus     UpdateStatus
+	ReadyReplicas    int
+}
+
+// Revision is a snapshot of the task template of a service.
+type Revision struct {
+	Service     string
+	Number      int
+	Template    task.Task
+	Timestamp   time.Time
+	Author      string
+	ChangeCause string
+}
+
+// UpdateConfig controls how tasks are replaced when the template changes.
+//
+// With the rolling strategy MaxSurge is the number of tasks that may run above
+// the desired replica count and MaxUnavailable is the number of replicas that
+// may be missing. Once FailureThreshold tasks of the new revision fail, the
+// update is rolled back.
+//
+// The canary strategy first runs CanaryReplicas tasks of the new revision next
+// to the full old set. With AutoPromote the canary is promoted to a rolling
+// update once CanaryChecks health checks have been made and at least
+// CanarySuccessRate of them passed, and aborted otherwise.
+//
+// The bluegreen strategy starts a full set of new tasks next to the old ones
+// and switches the active revision once all of them are healthy, either
+// automatically with AutoPromote or on request. The old set is stopped after
+// the switch.
+type UpdateConfig struct {
+	Strategy          string
+	MaxSurge          int
+	MaxUnavailable    int
+	FailureThreshold  int
+	CanaryReplicas    int
+	CanaryChecks      int
+	CanarySuccessRate float64
+	AutoPromote       bool
+}
+
+// UpdateStatus describes the progress of the latest update. Checks and
+// ChecksPassed count the health checks made against canary tasks.
+type UpdateStatus struct {
+	State        string
+	StartedAt    time.Time
+	CompletedAt  time.Time
+	Failures     int
+	Checks       int
+	ChecksPassed int
+	Message      string
+}
+
+func (s *Service) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("service must have a name")
+	}
+	if s.Replicas < 0 {
+		return fmt.Errorf("service %s cannot have a negative number of replicas", s.Name)
+	}
+	if s.Template.Image == "" {
+		return fmt.Errorf("service %s must have an image in its task template", s.Name)
+	}
+	err := s.Template.Validate()
+	if err != nil {
+		return fmt.Errorf("service %s: %v", s.Name, err)
+	}
+	c := s.UpdateConfig
+	if c.MaxSurge < 0 || c.MaxUnavailable < 0 || c.FailureThreshold < 0 || c.CanaryReplicas < 0 || c.CanaryChecks < 0 {
+		return fmt.Errorf("service %s has negative values in its update config", s.Name)
+	}
+	if c.CanarySuccessRate < 0 || c.CanarySuccessRate > 1 {
+		return fmt.Errorf("service %s must have a canary success rate between 0 and 1", s.Name)
+	}
+
+	switch c.Strategy {
+	case "", StrategyRolling, StrategyCanary, StrategyBlueGreen:
+	default:
+		return fmt.Errorf("service %s has unknown update strategy %s", s.Name, c.Strategy)
+	}
+
+	return nil
+}
+
+// Updating reports whether tasks of an older revision are being replaced.
+func (s *Service) Updating() bool {
+	switch s.UpdateStatus.State {
+	case UpdateInProgress, UpdateCanary, UpdateBlueGreen, UpdateRollingBack:
+		return true
+	}
+
+	return false
+}
+
+// UpdateState returns the state a new update starts in for the strategy.
+func (c UpdateConfig) UpdateState() string {
+	switch c.Strategy {
+	case StrategyCanary:
+		return UpdateCanary
+	case StrategyBlueGreen:
+		return UpdateBlueGreen
+	default:
+		return UpdateInProgress
+	}
+}
+
+// WithDefaults returns the update config with zero values replaced by the
+// defaults: a rolling update starting one extra task at a time, never going
+// below the desired replica count and rolled back after the first failure.
+// Canaries default to a single task that has to pass 5 checks out of 5.
+func (c UpdateConfig) WithDefaults() UpdateConfig {
+	if c.Strategy == "" {
+		c.Strategy = StrategyRolling
+	}
+	if c.MaxSurge == 0 && c.MaxUnavailable == 0 {
+		c.MaxSurge = 1
+	}
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = 1
+	}
+	if c.CanaryReplicas == 0 {
+		c.CanaryReplicas = 1
+	}
+	if c.CanaryChecks == 0 {
+		c.CanaryChecks = 5
+	}
+	if c.CanarySuccessRate == 0 {
+		c.CanarySuccessRate = 1
+	}
+
+	return c
+}
+
+func RevisionKey(service string, number int) string {
+	return fmt.Sprintf("%s/%d", service, number)
+}
@@ -34,6 +34,15 @@ func (s *InMemoryStore[V]) Count() (int, error) {
 	return len(s.db), nil
 }
 
+func (s *InMemoryStore[V]) Delete(key string) error {
+	_, ok := s.db[key]
+	if !ok {
+		return fmt.Errorf("value not found for key %s", key)
+	}
+	delete(s.db, key)
+	return nil
+}
+
 func NewInMemoryTaskStore[V any]() *InMemoryStore[V] {
 	return &InMemoryStore[V]{
 		db: make(map[string]V),
@@ -91,6 +91,17 @@ func (s *PersistentStore[V]) Count() (int, error) {
 	return taskCount, nil
 }
 
+func (s *PersistentStore[V]) Delete(key string) error {
+	return s.Db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(s.Bucket))
+		if b.Get([]byte(key)) == nil {
+			return fmt.Errorf("value for the key %s not found", key)
+		}
+
+		return b.Delete([]byte(key))
+	})
+}
+
 func (s *PersistentStore[V]) CreateBucket() error {
 	return s.Db.Update(func(tx *bolt.Tx) error {
 		_, err := tx.CreateBucket([]byte(s.Bucket))
@@ -7,6 +7,7 @@ type Store[V any] interface {
 	Get(key string) (V, error)
 	List() ([]V, error)
 	Count() (int, error)
+	Delete(key string) error
 }
 
 func NewOfType[V any](storeType string, name string) Store[V] {
@@ -0,0 +1,81 @@
+package task
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// CopyFrom returns a tar archive of a file or directory in the container.
+// Its entries are named after the last element of the path, and the ones
+// under it.
+func (d *Docker) CopyFrom(ctx context.Context, id string, srcPath string) (io.ReadCloser, error) {
+	r, _, err := d.Client.CopyFromContainer(ctx, id, srcPath)
+	return r, err
+}
+
+// CopyTo extracts a tar archive into a directory of the container.
+func (d *Docker) CopyTo(ctx context.Context, id string, dstDir string, archive io.Reader) error {
+	return d.Client.CopyToContainer(ctx, id, dstDir, archive, container.CopyToContainerOptions{})
+}
+
+// IsDir tells whether the path is an existing directory in the container.
+func (d *Docker) IsDir(ctx context.Context, id string, p string) (bool, error) {
+	stat, err := d.Client.ContainerStatPath(ctx, id, p)
+	if err != nil {
+		return false, err
+	}
+
+	return stat.Mode.IsDir(), nil
+}
+
+// RenameArchiveRoot renames the top-level entry of a tar archive, like the
+// ones made by CopyFrom, and everything under it, so that the archive
+// extracts to name.
+func RenameArchiveRoot(archive io.Reader, name string) io.ReadCloser {
+	r, w := io.Pipe()
+	go func() {
+		tr := tar.NewReader(archive)
+		tw := tar.NewWriter(w)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				w.CloseWithError(err)
+				return
+			}
+
+			hdr.Name = renameRoot(hdr.Name, name)
+			if hdr.Typeflag == tar.TypeLink {
+				hdr.Linkname = renameRoot(hdr.Linkname, name)
+			}
+			err = tw.WriteHeader(hdr)
+			if err == nil {
+				_, err = io.Copy(tw, tr)
+			}
+			if err != nil {
+				w.CloseWithError(err)
+				return
+			}
+		}
+
+		w.CloseWithError(tw.Close())
+	}()
+
+	return r
+}
+
+func renameRoot(p string, name string) string {
+	_, rest, found := strings.Cut(strings.TrimPrefix(path.Clean(p), "/"), "/")
+	if !found {
+		return name
+	}
+
+	return name + "/" + rest
+}
@@ -0,0 +1,90 @@
+package task
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestRenameArchiveRoot(t *testing.T) {
+	type entry struct {
+		name     string
+		typeflag byte
+		linkname string
+		body     string
+	}
+	tests := []struct {
+		name    string
+		entries []entry
+		to      string
+		want    []entry
+	}{
+		{
+			"file",
+			[]entry{{"app.log", tar.TypeReg, "", "hello"}},
+			"copy.log",
+			[]entry{{"copy.log", tar.TypeReg, "", "hello"}},
+		},
+		{
+			"directory",
+			[]entry{
+				{"data/", tar.TypeDir, "", ""},
+				{"data/a.txt", tar.TypeReg, "", "a"},
+				{"data/sub/b.txt", tar.TypeReg, "", "b"},
+				{"data/hard", tar.TypeLink, "data/a.txt", ""},
+				{"data/soft", tar.TypeSymlink, "a.txt", ""},
+			},
+			"backup",
+			[]entry{
+				{"backup", tar.TypeDir, "", ""},
+				{"backup/a.txt", tar.TypeReg, "", "a"},
+				{"backup/sub/b.txt", tar.TypeReg, "", "b"},
+				{"backup/hard", tar.TypeLink, "backup/a.txt", ""},
+				{"backup/soft", tar.TypeSymlink, "a.txt", ""},
+			},
+		},
+		{
+			"absolute names",
+			[]entry{{"/data/a.txt", tar.TypeReg, "", "a"}},
+			"backup",
+			[]entry{{"backup/a.txt", tar.TypeReg, "", "a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, e := range tt.entries {
+			tw.WriteHeader(&tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: 0o644, Size: int64(len(e.body))})
+			tw.Write([]byte(e.body))
+		}
+		tw.Close()
+
+		r := RenameArchiveRoot(&buf, tt.to)
+		tr := tar.NewReader(r)
+		got := []entry{}
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: reading the renamed archive failed: %v", tt.name, err)
+			}
+			body, _ := io.ReadAll(tr)
+			got = append(got, entry{hdr.Name, hdr.Typeflag, hdr.Linkname, string(body)})
+		}
+		r.Close()
+
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d entries, want %d: %v", tt.name, len(got), len(tt.want), got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: entry %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// ExecOptions describe a command to run in a running container of a task.
+// With Tty the command gets a terminal of Height rows and Width columns,
+// and its output comes back as is; otherwise stdout and stderr come back
+// multiplexed as Docker sends them. Container names a sidecar of the task,
+// the command runs in the main container if it is empty.
+type ExecOptions struct {
+	Cmd       []string
+	Tty       bool
+	Stdin     bool
+	Container string
+	Height    uint
+	Width     uint
+}
+
+// Query encodes the options as URL query parameters.
+func (o ExecOptions) Query() url.Values {
+	q := url.Values{}
+	for _, arg := range o.Cmd {
+		q.Add("cmd", arg)
+	}
+	if o.Tty {
+		q.Set("tty", "true")
+	}
+	if o.Stdin {
+		q.Set("stdin", "true")
+	}
+	if o.Container != "" {
+		q.Set("container", o.Container)
+	}
+	if o.Height > 0 && o.Width > 0 {
+		q.Set("h", strconv.FormatUint(uint64(o.Height), 10))
+		q.Set("w", strconv.FormatUint(uint64(o.Width), 10))
+	}
+
+	return q
+}
+
+// ParseExecOptions reads the options from URL query parameters.
+func ParseExecOptions(q url.Values) (ExecOptions, error) {
+	o := ExecOptions{
+		Cmd:       q["cmd"],
+		Tty:       q.Get("tty") == "true",
+		Stdin:     q.Get("stdin") == "true",
+		Container: q.Get("container"),
+	}
+	if len(o.Cmd) == 0 {
+		return o, fmt.Errorf("no command to run")
+	}
+
+	var err error
+	o.Height, o.Width, err = ParseTerminalSize(q)
+	if err != nil {
+		return o, err
+	}
+
+	return o, nil
+}
+
+// ParseTerminalSize reads the size of a terminal from the h and w URL query
+// parameters. Both are zero if the size isn't given.
+func ParseTerminalSize(q url.Values) (uint, uint, error) {
+	if q.Get("h") == "" && q.Get("w") == "" {
+		return 0, 0, nil
+	}
+
+	height, err := strconv.ParseUint(q.Get("h"), 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid terminal height %q", q.Get("h"))
+	}
+	width, err := strconv.ParseUint(q.Get("w"), 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid terminal width %q", q.Get("w"))
+	}
+
+	return uint(height), uint(width), nil
+}
+
+// ExecStatus is the state of a command run in a container.
+type ExecStatus struct {
+	ID          string
+	ContainerID string
+	Running     bool
+	ExitCode    int
+}
+
+// Exec starts a command in the container and attaches to it. The caller
+// writes stdin to the connection of the returned response and reads the
+// output from its reader, and has to close it.
+func (d *Docker) Exec(ctx context.Context, id string, o ExecOptions) (string, types.HijackedResponse, error) {
+	var size *[2]uint
+	if o.Tty && o.Height > 0 && o.Width > 0 {
+		size = &[2]uint{o.Height, o.Width}
+	}
+
+	resp, err := d.Client.ContainerExecCreate(ctx, id, container.ExecOptions{
+		Cmd:          o.Cmd,
+		Tty:          o.Tty,
+		ConsoleSize:  size,
+		AttachStdin:  o.Stdin,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return "", types.HijackedResponse{}, err
+	}
+
+	hijacked, err := d.Client.ContainerExecAttach(ctx, resp.ID, container.ExecAttachOptions{
+		Tty:         o.Tty,
+		ConsoleSize: size,
+	})
+	if err != nil {
+		return "", types.HijackedResponse{}, err
+	}
+
+	return resp.ID, hijacked, nil
+}
+
+// ResizeExec resizes the terminal of a command started with Exec.
+func (d *Docker) ResizeExec(ctx context.Context, execID string, height uint, width uint) error {
+	return d.Client.ContainerExecResize(ctx, execID, container.ResizeOptions{Height: height, Width: width})
+}
+
+// InspectExec returns the state of a command started with Exec.
+func (d *Docker) InspectExec(ctx context.Context, execID string) (ExecStatus, error) {
+	resp, err := d.Client.ContainerExecInspect(ctx, execID)
+	if err != nil {
+		return ExecStatus{}, err
+	}
+
+	return ExecStatus{
+		ID:          resp.ExecID,
+		ContainerID: resp.ContainerID,
+		Running:     resp.Running,
+		ExitCode:    resp.ExitCode,
+	}, nil
+}
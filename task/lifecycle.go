@@ -0,0 +1,65 @@
+package task
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/probe"
+)
+
+const (
+	HookExec = "exec"
+	HookHTTP = "http"
+)
+
+// Hook is an action run by the worker at a point in the life of a task:
+// PostStart right after the container has started and PreStop before the
+// container is sent its stop signal. An exec hook runs Command in the
+// container, an HTTP hook calls Path on Port of the container and expects a
+// status below 400. A hook is given TimeoutSeconds to finish, 30 by default.
+type Hook struct {
+	Type           string
+	Command        []string
+	Port           int
+	Path           string
+	TimeoutSeconds int
+}
+
+func (h *Hook) Validate() error {
+	switch h.Type {
+	case HookExec, HookHTTP:
+	default:
+		return fmt.Errorf("unknown hook type %q", h.Type)
+	}
+
+	return h.Probe().Validate()
+}
+
+// Probe returns a probe that runs the hook once. HTTP hooks succeed with the
+// same statuses as HTTP probes, and all hooks get 30 seconds by default.
+func (h *Hook) Probe() *probe.Probe {
+	p := probe.Probe{
+		Type:           h.Type,
+		Command:        h.Command,
+		Port:           h.Port,
+		Path:           h.Path,
+		TimeoutSeconds: h.TimeoutSeconds,
+	}
+	if p.TimeoutSeconds == 0 {
+		p.TimeoutSeconds = 30
+	}
+	p = p.WithDefaults()
+
+	return &p
+}
+
+// StopTimeout returns how long the container of the task is given to exit
+// after its stop signal before it is killed, the Docker default of 10
+// seconds if StopGracePeriodSeconds is not set.
+func (t *Task) StopTimeout() time.Duration {
+	if t.StopGracePeriodSeconds == 0 {
+		return 10 * time.Second
+	}
+
+	return time.Duration(t.StopGracePeriodSeconds) * time.Second
+}
@@ -0,0 +1,89 @@
+package task
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// LogOptions select the logs of a task to read. Tail is the number of lines
+// to read from the end, or "all". Since is a timestamp in RFC 3339 format or
+// a duration like 10m, counted back from now. Container names a sidecar of
+// the task, the main container is read if it is empty.
+type LogOptions struct {
+	Follow     bool
+	Tail       string
+	Since      string
+	Timestamps bool
+	Container  string
+}
+
+// Query encodes the options as URL query parameters.
+func (o LogOptions) Query() url.Values {
+	q := url.Values{}
+	if o.Follow {
+		q.Set("follow", "true")
+	}
+	if o.Tail != "" {
+		q.Set("tail", o.Tail)
+	}
+	if o.Since != "" {
+		q.Set("since", o.Since)
+	}
+	if o.Timestamps {
+		q.Set("timestamps", "true")
+	}
+	if o.Container != "" {
+		q.Set("container", o.Container)
+	}
+
+	return q
+}
+
+// ParseLogOptions reads the options from URL query parameters.
+func ParseLogOptions(q url.Values) (LogOptions, error) {
+	o := LogOptions{
+		Follow:     q.Get("follow") == "true",
+		Tail:       q.Get("tail"),
+		Since:      q.Get("since"),
+		Timestamps: q.Get("timestamps") == "true",
+		Container:  q.Get("container"),
+	}
+
+	if o.Tail != "" && o.Tail != "all" {
+		n, err := strconv.Atoi(o.Tail)
+		if err != nil || n < 0 {
+			return o, fmt.Errorf("invalid tail %q, expected a number of lines or all", o.Tail)
+		}
+	}
+	if o.Since != "" {
+		_, err := time.ParseDuration(o.Since)
+		if err != nil {
+			_, err = time.Parse(time.RFC3339, o.Since)
+		}
+		if err != nil {
+			return o, fmt.Errorf("invalid since %q, expected a duration or an RFC 3339 timestamp", o.Since)
+		}
+	}
+
+	return o, nil
+}
+
+// Logs streams the logs of the container, stdout and stderr multiplexed as
+// Docker sends them, until the context is done or, unless following, the end
+// of the logs is reached.
+func (d *Docker) Logs(ctx context.Context, id string, o LogOptions) (io.ReadCloser, error) {
+	return d.Client.ContainerLogs(ctx, id, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     o.Follow,
+		Tail:       o.Tail,
+		Since:      o.Since,
+		Timestamps: o.Timestamps,
+	})
+}
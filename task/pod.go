@@ -0,0 +1,220 @@
+package task
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/api/types/mount"
+)
+
+// Container is a container run next to the main container of a task. Init
+// containers run one after the other before the main container starts, and
+// each has to exit with code 0 for the task to start. Sidecars start right
+// after the main container, share its network namespace, so they reach it
+// on localhost, and are stopped along with it.
+//
+// All containers of a task run on the same worker and can mount the volumes
+// of the task.
+type Container struct {
+	Name         string
+	Image        string
+	Cmd          []string
+	Env          []string
+	Memory       int
+	Disk         int
+	Cpu          float64
+	VolumeMounts []VolumeMount
+}
+
+// MainContainer is the name the main container of a task goes by, which the
+// other containers of the task cannot use.
+const MainContainer = "main"
+
+// VolumeMount mounts the volume of the task called Name at MountPath.
+type VolumeMount struct {
+	Name      string
+	MountPath string
+	ReadOnly  bool
+}
+
+// validateContainers checks the init containers, sidecars and volumes of
+// the task.
+func (t *Task) validateContainers() error {
+	volumes := make(map[string]bool)
+	for _, v := range t.Volumes {
+		if v == "" {
+			return fmt.Errorf("volume must have a name")
+		}
+		if volumes[v] {
+			return fmt.Errorf("duplicate volume %s", v)
+		}
+		volumes[v] = true
+	}
+
+	err := validateMounts(t.VolumeMounts, volumes)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string]bool)
+	for _, c := range append(append([]Container{}, t.InitContainers...), t.Sidecars...) {
+		if c.Name == "" || c.Image == "" {
+			return fmt.Errorf("container must have a name and an image")
+		}
+		if c.Name == MainContainer {
+			return fmt.Errorf("container name %s is reserved for the main container", c.Name)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("duplicate container %s", c.Name)
+		}
+		names[c.Name] = true
+
+		if c.Memory < 0 || c.Disk < 0 || c.Cpu < 0 {
+			return fmt.Errorf("container %s cannot have negative resources", c.Name)
+		}
+
+		err := validateMounts(c.VolumeMounts, volumes)
+		if err != nil {
+			return fmt.Errorf("container %s: %v", c.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func validateMounts(mounts []VolumeMount, volumes map[string]bool) error {
+	for _, m := range mounts {
+		if !volumes[m.Name] {
+			return fmt.Errorf("unknown volume %s", m.Name)
+		}
+		if m.MountPath == "" {
+			return fmt.Errorf("mount of volume %s must have a path", m.Name)
+		}
+	}
+
+	return nil
+}
+
+// TotalMemory returns the memory the task needs on its worker: the main
+// container and its sidecars run together, while init containers run alone
+// before them.
+func (t *Task) TotalMemory() int {
+	total := t.Memory
+	for _, c := range t.Sidecars {
+		total += c.Memory
+	}
+	for _, c := range t.InitContainers {
+		total = max(total, c.Memory)
+	}
+
+	return total
+}
+
+// TotalDisk returns the disk the task needs on its worker, in the same way
+// as TotalMemory.
+func (t *Task) TotalDisk() int {
+	total := t.Disk
+	for _, c := range t.Sidecars {
+		total += c.Disk
+	}
+	for _, c := range t.InitContainers {
+		total = max(total, c.Disk)
+	}
+
+	return total
+}
+
+// TotalCpu returns the CPUs the task needs on its worker, in the same way as
+// TotalMemory.
+func (t *Task) TotalCpu() float64 {
+	total := t.Cpu
+	for _, c := range t.Sidecars {
+		total += c.Cpu
+	}
+	for _, c := range t.InitContainers {
+		total = max(total, c.Cpu)
+	}
+
+	return total
+}
+
+// Images returns the images of all the containers of the task, each once.
+func (t *Task) Images() []string {
+	all := []string{}
+	for _, c := range t.InitContainers {
+		all = append(all, c.Image)
+	}
+	all = append(all, t.Image)
+	for _, c := range t.Sidecars {
+		all = append(all, c.Image)
+	}
+
+	images := []string{}
+	seen := make(map[string]bool)
+	for _, image := range all {
+		if !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+
+	return images
+}
+
+// VolumeName returns the name of the Docker volume backing a volume of the
+// task. Volumes live as long as the main container of the task, every run
+// starts with empty ones.
+func (t *Task) VolumeName(name string) string {
+	return fmt.Sprintf("%s-%s", t.ID, name)
+}
+
+// VolumeNames returns the names of the Docker volumes of the task.
+func (t *Task) VolumeNames() []string {
+	names := []string{}
+	for _, v := range t.Volumes {
+		names = append(names, t.VolumeName(v))
+	}
+
+	return names
+}
+
+func (t *Task) mounts(mounts []VolumeMount) []mount.Mount {
+	result := []mount.Mount{}
+	for _, m := range mounts {
+		result = append(result, mount.Mount{
+			Type:     mount.TypeVolume,
+			Source:   t.VolumeName(m.Name),
+			Target:   m.MountPath,
+			ReadOnly: m.ReadOnly,
+		})
+	}
+
+	return result
+}
+
+// NewContainerConfig returns the configuration of an init container or a
+// sidecar of the task. Sidecars join the network namespace of the main
+// container, given by its ID.
+func NewContainerConfig(t *Task, c Container, mainContainerID string) *Config {
+	prefix := t.Name
+	if prefix == "" {
+		prefix = t.ID.String()
+	}
+
+	config := &Config{
+		Name:        fmt.Sprintf("%s-%s", prefix, c.Name),
+		Image:       c.Image,
+		Cmd:         c.Cmd,
+		Env:         c.Env,
+		Cpu:         c.Cpu,
+		Memory:      int64(c.Memory),
+		Disk:        int64(c.Disk),
+		Mounts:      t.mounts(c.VolumeMounts),
+		StopSignal:  t.StopSignal,
+		StopTimeout: t.StopTimeout(),
+	}
+	if mainContainerID != "" {
+		config.NetworkMode = fmt.Sprintf("container:%s", mainContainerID)
+	}
+
+	return config
+}
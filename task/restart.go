@@ -0,0 +1,134 @@
+package task
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// RestartPolicy tells the manager what to do when a task fails. With
+// on-failure a task is restarted unless its container exited with code 0,
+// with always it is restarted whatever the exit code. Jobs always run to
+// completion, so they cannot use always.
+//
+// A task is restarted at most MaxRetries times, waiting BackoffSeconds
+// before the first restart and twice as long before each following one, up
+// to MaxBackoffSeconds. A task that ran for ResetAfterSeconds before failing
+// starts counting its restarts from zero again.
+type RestartPolicy struct {
+	Mode              string
+	MaxRetries        *int
+	BackoffSeconds    int
+	MaxBackoffSeconds int
+	ResetAfterSeconds int
+}
+
+// UnmarshalJSON also accepts a plain string, which sets the mode and keeps
+// the defaults for everything else. The Docker names "no" and
+// "unless-stopped" are understood as well.
+func (p *RestartPolicy) UnmarshalJSON(data []byte) error {
+	var mode string
+	if json.Unmarshal(data, &mode) == nil {
+		switch mode {
+		case "no":
+			mode = RestartNever
+		case "unless-stopped":
+			mode = RestartAlways
+		}
+		*p = RestartPolicy{Mode: mode}
+		return nil
+	}
+
+	type plain RestartPolicy
+	return json.Unmarshal(data, (*plain)(p))
+}
+
+func (p RestartPolicy) Validate(kind string) error {
+	switch p.Mode {
+	case "", RestartNever, RestartOnFailure:
+	case RestartAlways:
+		if kind == KindJob {
+			return fmt.Errorf("restart policy %s cannot be used for jobs", p.Mode)
+		}
+	default:
+		return fmt.Errorf("unknown restart policy %s", p.Mode)
+	}
+
+	if (p.MaxRetries != nil && *p.MaxRetries < 0) || p.BackoffSeconds < 0 || p.MaxBackoffSeconds < 0 || p.ResetAfterSeconds < 0 {
+		return fmt.Errorf("restart policy cannot have negative values")
+	}
+
+	return nil
+}
+
+// WithDefaults returns a copy of the policy with zero values replaced by the
+// defaults: restarts on failure, up to 3 of them, starting with a 10 second
+// backoff capped at 5 minutes, and a reset after 10 minutes of running.
+func (p RestartPolicy) WithDefaults() RestartPolicy {
+	if p.Mode == "" {
+		p.Mode = RestartOnFailure
+	}
+	if p.MaxRetries == nil {
+		maxRetries := 3
+		p.MaxRetries = &maxRetries
+	}
+	if p.BackoffSeconds == 0 {
+		p.BackoffSeconds = 10
+	}
+	if p.MaxBackoffSeconds == 0 {
+		p.MaxBackoffSeconds = 300
+	}
+	if p.ResetAfterSeconds == 0 {
+		p.ResetAfterSeconds = 600
+	}
+
+	return p
+}
+
+// Restarts reports whether the policy restarts the failed task. It doesn't
+// look at the number of restarts so far. Tasks that ran out of time, or
+// that were already given up after too many restarts, are never restarted.
+func (p RestartPolicy) Restarts(t *Task) bool {
+	if t.Reason == ReasonDeadlineExceeded || t.Reason == ReasonRetriesExhausted {
+		return false
+	}
+
+	switch p.Mode {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return t.Reason != ReasonError || t.ExitCode != 0
+	}
+
+	return false
+}
+
+// Backoff returns how long to wait before the restart that follows the given
+// number of restarts.
+func (p RestartPolicy) Backoff(restarts int) time.Duration {
+	return ExponentialBackoff(p.BackoffSeconds, p.MaxBackoffSeconds, restarts)
+}
+
+// ExponentialBackoff returns initialSeconds doubled the given number of
+// times, capped at maxSeconds.
+func ExponentialBackoff(initialSeconds int, maxSeconds int, doublings int) time.Duration {
+	backoff := time.Duration(initialSeconds) * time.Second
+	limit := time.Duration(maxSeconds) * time.Second
+	for i := 0; i < doublings && backoff < limit; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, limit)
+}
+
+// ResetAfter returns how long a task has to run for its previous restarts to
+// be forgotten.
+func (p RestartPolicy) ResetAfter() time.Duration {
+	return time.Duration(p.ResetAfterSeconds) * time.Second
+}
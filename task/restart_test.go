@@ -0,0 +1,41 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	tests := []struct {
+		initial   int
+		max       int
+		doublings int
+		want      time.Duration
+	}{
+		{10, 300, 0, 10 * time.Second},
+		{10, 300, 1, 20 * time.Second},
+		{10, 300, 3, 80 * time.Second},
+		{10, 300, 5, 300 * time.Second},
+		{10, 300, 100, 300 * time.Second},
+		{10, 5, 0, 5 * time.Second},
+		{0, 300, 4, 0},
+	}
+
+	for _, tt := range tests {
+		got := ExponentialBackoff(tt.initial, tt.max, tt.doublings)
+		if got != tt.want {
+			t.Errorf("ExponentialBackoff(%d, %d, %d) = %v, want %v", tt.initial, tt.max, tt.doublings, got, tt.want)
+		}
+	}
+}
+
+func TestRestartPolicyBackoff(t *testing.T) {
+	p := RestartPolicy{}.WithDefaults()
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second, 160 * time.Second, 300 * time.Second}
+	for restarts, w := range want {
+		got := p.Backoff(restarts)
+		if got != w {
+			t.Errorf("Backoff(%d) = %v, want %v", restarts, got, w)
+		}
+	}
+}
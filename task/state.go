@@ -1,11 +1,34 @@
 package task
 
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// A task is Scheduled once the manager has placed it on a worker, and the
+// worker pulls its image and creates its container before it is Running.
+// A failed task can be stopped, which removes its container, or restarted
+// right away or after waiting in CrashLoopBackOff. Completed tasks are done
+// for good.
+//
+// The worker marks a task Unknown while it cannot inspect its container, and
+// the manager marks a task Lost while its worker cannot be reached. Both go
+// back to whatever state is reported next, unless the task has been lost for
+// so long that the manager gave it up as Failed.
 var stateTransitionMap = map[State][]State{
-	Pending:   {Scheduled},
-	Scheduled: {Scheduled, Running, Failed},
-	Running:   {Running, Completed, Failed, Scheduled},
-	Completed: {},
-	Failed:    {Scheduled},
+	Pending:          {Scheduled, Completed, Failed},
+	Scheduled:        {Scheduled, Pulling, Starting, Running, Completed, Failed, Lost},
+	Pulling:          {Starting, Completed, Failed, Lost},
+	Starting:         {Running, Completed, Failed, Lost},
+	Running:          {Paused, Stopping, Completed, Failed, Unknown, Lost},
+	Completed:        {},
+	Failed:           {Pending, Scheduled, Completed, CrashLoopBackOff},
+	CrashLoopBackOff: {Scheduled, Completed, Failed},
+	Stopping:         {Completed, Failed, Lost},
+	Paused:           {Running, Stopping, Completed, Failed, Lost},
+	Unknown:          {Running, Stopping, Completed, Failed, Lost},
+	Lost:             {Scheduled, Pulling, Starting, Running, Paused, Stopping, Completed, Failed, Unknown},
 }
 
 func contains(states []State, state State) bool {
@@ -21,3 +44,70 @@ func contains(states []State, state State) bool {
 func ValidStateTransition(src State, dst State) bool {
 	return contains(stateTransitionMap[src], dst)
 }
+
+// MaxTransitions is the number of state transitions kept in the history of a
+// task.
+const MaxTransitions = 50
+
+// Transition records a change of the state of a task, and why it happened.
+type Transition struct {
+	From    State
+	To      State
+	Time    time.Time
+	Reason  string
+	Message string
+}
+
+// SetState moves the task to a new state, sets its Reason and Message and
+// records the transition in its history. Setting the state the task is
+// already in for the same reason changes nothing. Transitions missing from
+// the state machine are still made, but logged, since the task has to
+// follow what happened to its container either way.
+func (t *Task) SetState(state State, reason string, message string) {
+	if t.State == state && t.Reason == reason && t.Message == message {
+		return
+	}
+	if t.State != state && !ValidStateTransition(t.State, state) {
+		log.Printf("Unexpected transition of task %s from %v to %v (%s)\n", t.ID, t.State, state, reason)
+	}
+
+	t.Transitions = append(t.Transitions, Transition{
+		From:    t.State,
+		To:      state,
+		Time:    time.Now().UTC(),
+		Reason:  reason,
+		Message: message,
+	})
+	if len(t.Transitions) > MaxTransitions {
+		t.Transitions = t.Transitions[len(t.Transitions)-MaxTransitions:]
+	}
+
+	t.State = state
+	t.Reason = reason
+	t.Message = message
+}
+
+// MergeTransitions adds the transitions recorded elsewhere, by the worker
+// for the manager, to the history of the task. Transitions both sides know
+// of are kept once, and the history is ordered by time.
+func (t *Task) MergeTransitions(transitions []Transition) {
+	for _, tr := range transitions {
+		known := false
+		for _, existing := range t.Transitions {
+			if existing.From == tr.From && existing.To == tr.To && existing.Time.Equal(tr.Time) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			t.Transitions = append(t.Transitions, tr)
+		}
+	}
+
+	sort.SliceStable(t.Transitions, func(i, j int) bool {
+		return t.Transitions[i].Time.Before(t.Transitions[j].Time)
+	})
+	if len(t.Transitions) > MaxTransitions {
+		t.Transitions = t.Transitions[len(t.Transitions)-MaxTransitions:]
+	}
+}
@@ -0,0 +1,114 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidStateTransition(t *testing.T) {
+	tests := []struct {
+		src  State
+		dst  State
+		want bool
+	}{
+		{Pending, Scheduled, true},
+		{Pending, Running, false},
+		{Scheduled, Pulling, true},
+		{Pulling, Starting, true},
+		{Starting, Running, true},
+		{Running, Stopping, true},
+		{Running, Pending, false},
+		{Stopping, Completed, true},
+		{Stopping, Running, false},
+		{Completed, Running, false},
+		{Completed, Failed, false},
+		{Failed, CrashLoopBackOff, true},
+		{CrashLoopBackOff, Scheduled, true},
+		{CrashLoopBackOff, Running, false},
+		{Running, Unknown, true},
+		{Unknown, Running, true},
+		{Running, Lost, true},
+		{Lost, Running, true},
+		{Lost, Failed, true},
+		{Lost, Pending, false},
+		{Paused, Running, true},
+	}
+
+	for _, tt := range tests {
+		got := ValidStateTransition(tt.src, tt.dst)
+		if got != tt.want {
+			t.Errorf("ValidStateTransition(%v, %v) = %v, want %v", tt.src, tt.dst, got, tt.want)
+		}
+	}
+}
+
+func TestSetState(t *testing.T) {
+	tk := Task{}
+	tk.SetState(Scheduled, ReasonScheduled, "")
+	tk.SetState(Running, ReasonStarted, "")
+	// The same state for the same reason is not recorded again.
+	tk.SetState(Running, ReasonStarted, "")
+	tk.SetState(Failed, ReasonError, "exit code 1")
+
+	want := []struct {
+		from   State
+		to     State
+		reason string
+	}{
+		{Pending, Scheduled, ReasonScheduled},
+		{Scheduled, Running, ReasonStarted},
+		{Running, Failed, ReasonError},
+	}
+	if len(tk.Transitions) != len(want) {
+		t.Fatalf("got %d transitions, want %d: %v", len(tk.Transitions), len(want), tk.Transitions)
+	}
+	for i, w := range want {
+		tr := tk.Transitions[i]
+		if tr.From != w.from || tr.To != w.to || tr.Reason != w.reason {
+			t.Errorf("transition %d = %v -> %v (%s), want %v -> %v (%s)", i, tr.From, tr.To, tr.Reason, w.from, w.to, w.reason)
+		}
+	}
+	if tk.State != Failed || tk.Reason != ReasonError || tk.Message != "exit code 1" {
+		t.Errorf("task is %v (%s: %s), want %v (%s: %s)", tk.State, tk.Reason, tk.Message, Failed, ReasonError, "exit code 1")
+	}
+}
+
+func TestSetStateKeepsLatestTransitions(t *testing.T) {
+	tk := Task{}
+	for i := 0; i < MaxTransitions+10; i++ {
+		if i%2 == 0 {
+			tk.SetState(Running, ReasonStarted, "")
+		} else {
+			tk.SetState(Paused, ReasonPaused, "")
+		}
+	}
+
+	if len(tk.Transitions) != MaxTransitions {
+		t.Fatalf("got %d transitions, want %d", len(tk.Transitions), MaxTransitions)
+	}
+	last := tk.Transitions[len(tk.Transitions)-1]
+	if last.To != tk.State {
+		t.Errorf("last transition is to %v, want %v", last.To, tk.State)
+	}
+}
+
+func TestMergeTransitions(t *testing.T) {
+	now := time.Now().UTC()
+	scheduled := Transition{From: Pending, To: Scheduled, Time: now}
+	started := Transition{From: Scheduled, To: Running, Time: now.Add(time.Second)}
+	failed := Transition{From: Running, To: Failed, Time: now.Add(2 * time.Second)}
+
+	tk := Task{Transitions: []Transition{scheduled, failed}}
+	tk.MergeTransitions([]Transition{scheduled, started})
+
+	want := []Transition{scheduled, started, failed}
+	if len(tk.Transitions) != len(want) {
+		t.Fatalf("got %d transitions, want %d: %v", len(tk.Transitions), len(want), tk.Transitions)
+	}
+	for i, w := range want {
+		tr := tk.Transitions[i]
+		if tr.From != w.From || tr.To != w.To || !tr.Time.Equal(w.Time) {
+			t.Errorf("transition %d = %v -> %v, want %v -> %v", i, tr.From, tr.To, w.From, w.To)
+		}
+	}
+}
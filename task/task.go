@@ -1,15 +1,20 @@
 package task
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"log"
 	"math"
-	"os"
+	"strings"
 	"time"
 
+	"github.com/d-bolshakov/orchestrator/probe"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
@@ -24,6 +29,13 @@ const (
 	Running
 	Completed
 	Failed
+	CrashLoopBackOff
+	Stopping
+	Paused
+	Pulling
+	Starting
+	Lost
+	Unknown
 )
 
 func (s State) String() string {
@@ -43,27 +55,285 @@ func (s State) String() string {
 	case Failed:
 		return "Failed"
 
+	case CrashLoopBackOff:
+		return "CrashLoopBackOff"
+
+	case Stopping:
+		return "Stopping"
+
+	case Paused:
+		return "Paused"
+
+	case Pulling:
+		return "Pulling"
+
+	case Starting:
+		return "Starting"
+
+	case Lost:
+		return "Lost"
+
+	case Unknown:
+		return "Unknown"
+
 	default:
 		return "Unknown"
 	}
 }
 
+// KindJob marks tasks that run to completion. A job task whose container
+// exits with code 0 is Completed, while other tasks are expected to keep
+// running and are Failed whenever their container exits.
+const KindJob = "job"
+
+// Reasons given for the state of a task.
+const (
+	ReasonSubmitted         = "Submitted"
+	ReasonScheduled         = "Scheduled"
+	ReasonMigrated          = "Migrated"
+	ReasonPullingImage      = "PullingImage"
+	ReasonImagePullError    = "ImagePullError"
+	ReasonCreatingContainer = "CreatingContainer"
+	ReasonStarted           = "Started"
+	ReasonPaused            = "Paused"
+	ReasonResumed           = "Resumed"
+	ReasonStopRequested     = "StopRequested"
+	ReasonStopped           = "Stopped"
+	ReasonCompleted         = "Completed"
+	ReasonError             = "Error"
+	ReasonOOMKilled         = "OOMKilled"
+	ReasonStartError        = "StartError"
+	ReasonInitError         = "InitError"
+	ReasonSidecarExited     = "SidecarExited"
+	ReasonContainerMissing  = "ContainerMissing"
+	ReasonUnhealthy         = "Unhealthy"
+	ReasonHookError         = "HookError"
+	ReasonDeadlineExceeded  = "DeadlineExceeded"
+	ReasonCrashLoopBackOff  = "CrashLoopBackOff"
+	ReasonRestarting        = "Restarting"
+	ReasonRetriesExhausted  = "RetriesExhausted"
+	ReasonInspectError      = "InspectError"
+	ReasonWorkerUnreachable = "WorkerUnreachable"
+)
+
+// Task is a container to be run on a worker. Tasks sharing a ConcurrencyKey
+// run at most ConcurrencyLimit at a time across the cluster, or one at a
+// time if no limit is given; the manager holds the others in its queue until
+// a slot frees up.
+//
+// The worker checks a running task with its probes. A task whose liveness
+// probe fails is stopped and marked Failed, and the task is Ready only while
+// its readiness probe passes. Neither probe runs before the startup probe has
+// passed, so slow starting tasks aren't killed early, and a task whose
+// startup probe fails is stopped as well.
+//
+// A task can run init containers before its main container and sidecars
+// next to it, see Container, all sharing the volumes of the task. Each
+// container is limited to the Cpu number of CPUs it asks for, if any.
+//
+// A task is stopped gracefully: its PreStop hook runs first, then the
+// container is sent StopSignal and given StopGracePeriodSeconds to exit,
+// during which the task is Stopping.
+//
+// A task that runs for longer than ActiveDeadlineSeconds is stopped by its
+// worker, and a task that hasn't been placed on a worker within
+// SchedulingDeadlineSeconds of being submitted, or of its NotBefore time, is
+// given up by the manager. Either way the task fails with DeadlineExceeded
+// and isn't restarted.
+//
+// A task the descheduler starts to replace a task on a busier worker has
+// MigratedFrom set to the task it replaces, until it is ready and the other
+// task is stopped.
+//
+// Every change of State goes through SetState, which gives the Reason and
+// Message of the new state and records it in Transitions.
 type Task struct {
-	ID            uuid.UUID
-	Name          string
-	State         State
-	Image         string
-	Memory        int
-	Disk          int
-	ExposedPorts  nat.PortSet
-	PortBindings  map[string]string
-	HostPorts     nat.PortMap
-	RestartPolicy string
-	StartTime     time.Time
-	FinishTime    time.Time
-	ContainerID   string
-	HealthCheck   string
-	RestartCount  int
+	ID                        uuid.UUID
+	Name                      string
+	Kind                      string
+	State                     State
+	Image                     string
+	Cmd                       []string
+	Env                       []string
+	Memory                    int
+	Disk                      int
+	Cpu                       float64
+	ExposedPorts              nat.PortSet
+	PortBindings              map[string]string
+	HostPorts                 nat.PortMap
+	InitContainers            []Container
+	Sidecars                  []Container
+	Volumes                   []string
+	VolumeMounts              []VolumeMount
+	RestartPolicy             RestartPolicy
+	NotBefore                 time.Time
+	SubmitTime                time.Time
+	ActiveDeadlineSeconds     int
+	SchedulingDeadlineSeconds int
+	ConcurrencyKey            string
+	ConcurrencyLimit          int
+	StartTime                 time.Time
+	FinishTime                time.Time
+	ContainerID               string
+	SidecarContainerIDs       []string
+	HealthCheck               string
+	LivenessProbe             *probe.Probe
+	ReadinessProbe            *probe.Probe
+	StartupProbe              *probe.Probe
+	LivenessStatus            probe.Status
+	ReadinessStatus           probe.Status
+	StartupStatus             probe.Status
+	Ready                     bool
+	StopSignal                string
+	StopGracePeriodSeconds    int
+	PostStart                 *Hook
+	PreStop                   *Hook
+	RestartCount              int
+	NextRetryTime             time.Time
+	Labels                    map[string]string
+	Service                   string
+	Revision                  int
+	Job                       string
+	JobIndex                  int
+	Workflow                  string
+	StopRequested             bool
+	MigratedFrom              uuid.UUID
+	ExitCode                  int
+	Reason                    string
+	Message                   string
+	Transitions               []Transition
+	Outputs                   map[string]string
+}
+
+// Validate checks the parts of the task specification that the manager acts
+// on.
+func (t *Task) Validate() error {
+	err := t.RestartPolicy.Validate(t.Kind)
+	if err != nil {
+		return err
+	}
+
+	err = t.validateContainers()
+	if err != nil {
+		return err
+	}
+
+	if t.Memory < 0 || t.Disk < 0 || t.Cpu < 0 {
+		return fmt.Errorf("task cannot have negative resources")
+	}
+	if t.StopGracePeriodSeconds < 0 || t.ActiveDeadlineSeconds < 0 || t.SchedulingDeadlineSeconds < 0 {
+		return fmt.Errorf("stop grace period and deadlines cannot be negative")
+	}
+	hooks := map[string]*Hook{
+		"postStart": t.PostStart,
+		"preStop":   t.PreStop,
+	}
+	for name, h := range hooks {
+		if h == nil {
+			continue
+		}
+
+		err := h.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid %s hook: %v", name, err)
+		}
+	}
+
+	probes := map[string]*probe.Probe{
+		"liveness":  t.LivenessProbe,
+		"readiness": t.ReadinessProbe,
+		"startup":   t.StartupProbe,
+	}
+	for name, p := range probes {
+		if p == nil {
+			continue
+		}
+
+		err := p.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid %s probe: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Liveness returns the liveness probe of the task. A plain HealthCheck path
+// is probed over HTTP on the first exposed port.
+func (t *Task) Liveness() *probe.Probe {
+	if t.LivenessProbe != nil {
+		return t.LivenessProbe
+	}
+	if t.HealthCheck != "" {
+		return &probe.Probe{Type: probe.TypeHTTP, Path: t.HealthCheck, MinStatus: 200, MaxStatus: 200}
+	}
+
+	return nil
+}
+
+// ResetProbes forgets the results of the probes of an earlier run.
+func (t *Task) ResetProbes() {
+	t.LivenessStatus = probe.Status{}
+	t.ReadinessStatus = probe.Status{}
+	t.StartupStatus = probe.Status{}
+	t.Ready = false
+}
+
+// ActiveDeadline returns the time by which the task has to finish, and
+// whether it has such a deadline at all.
+func (t *Task) ActiveDeadline() (time.Time, bool) {
+	if t.ActiveDeadlineSeconds == 0 || t.StartTime.IsZero() {
+		return time.Time{}, false
+	}
+
+	return t.StartTime.Add(time.Duration(t.ActiveDeadlineSeconds) * time.Second), true
+}
+
+// SchedulingDeadline returns the time by which the task has to be placed on
+// a worker, and whether it has such a deadline at all.
+func (t *Task) SchedulingDeadline() (time.Time, bool) {
+	if t.SchedulingDeadlineSeconds == 0 {
+		return time.Time{}, false
+	}
+
+	start := t.SubmitTime
+	if t.NotBefore.After(start) {
+		start = t.NotBefore
+	}
+	return start.Add(time.Duration(t.SchedulingDeadlineSeconds) * time.Second), true
+}
+
+// MaxOutputSize limits the total size of the outputs a task can report.
+const MaxOutputSize = 4096
+
+// ParseOutputs collects the outputs a task reports on its standard output
+// with lines of the form "::set-output name=value". Later values win, and
+// outputs beyond MaxOutputSize are dropped.
+func ParseOutputs(r io.Reader) map[string]string {
+	outputs := make(map[string]string)
+	size := 0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "::set-output ")
+		if !ok {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok || name == "" {
+			continue
+		}
+
+		if size+len(name)+len(value) > MaxOutputSize {
+			log.Printf("Dropping output %s, outputs are limited to %d bytes\n", name, MaxOutputSize)
+			continue
+		}
+		size += len(name) + len(value)
+		outputs[name] = value
+	}
+
+	return outputs
 }
 
 type TaskEvent struct {
@@ -74,29 +344,37 @@ type TaskEvent struct {
 }
 
 type Config struct {
-	Name          string
-	AttachStdin   bool
-	AttachStdout  bool
-	AttachStderr  bool
-	ExposedPorts  nat.PortSet
-	Cmd           []string
-	Image         string
-	Cpu           float64
-	Memory        int64
-	Disk          int64
-	Env           []string
-	RestartPolicy string
-	ContainerID   string
+	Name         string
+	AttachStdin  bool
+	AttachStdout bool
+	AttachStderr bool
+	ExposedPorts nat.PortSet
+	Cmd          []string
+	Image        string
+	Cpu          float64
+	Memory       int64
+	Disk         int64
+	Env          []string
+	Mounts       []mount.Mount
+	NetworkMode  string
+	StopSignal   string
+	StopTimeout  time.Duration
+	ContainerID  string
 }
 
 func NewConfig(t *Task) *Config {
 	return &Config{
-		Name:          t.Name,
-		Image:         t.Image,
-		Memory:        int64(t.Memory),
-		Disk:          int64(t.Disk),
-		ExposedPorts:  t.ExposedPorts,
-		RestartPolicy: t.RestartPolicy,
+		Name:         t.Name,
+		Image:        t.Image,
+		Cmd:          t.Cmd,
+		Env:          t.Env,
+		Cpu:          t.Cpu,
+		Memory:       int64(t.Memory),
+		Disk:         int64(t.Disk),
+		ExposedPorts: t.ExposedPorts,
+		Mounts:       t.mounts(t.VolumeMounts),
+		StopSignal:   t.StopSignal,
+		StopTimeout:  t.StopTimeout(),
 	}
 }
 
@@ -117,19 +395,27 @@ type DockerInspectResponse struct {
 	Container *container.InspectResponse
 }
 
-func (d *Docker) Run() DockerResult {
-	ctx := context.Background()
-	reader, err := d.Client.ImagePull(ctx, d.Config.Image, image.PullOptions{})
+func (d *Docker) PullImage(name string) DockerResult {
+	reader, err := d.Client.ImagePull(context.Background(), name, image.PullOptions{})
 	if err != nil {
-		log.Printf("Error pulling image %s: %v\n", d.Config.Image, err)
+		log.Printf("Error pulling image %s: %v\n", name, err)
 		return DockerResult{Error: err}
 	}
-	io.Copy(os.Stdout, reader)
+	defer reader.Close()
 
-	rp := container.RestartPolicy{
-		Name: container.RestartPolicyMode(d.Config.RestartPolicy),
+	// The pull only completes once its progress has been read.
+	_, err = io.Copy(io.Discard, reader)
+	if err != nil {
+		log.Printf("Error pulling image %s: %v\n", name, err)
+		return DockerResult{Error: err}
 	}
 
+	return DockerResult{Action: "pull", Result: "success"}
+}
+
+// Start creates and starts the container, once its image has been pulled.
+func (d *Docker) Start() DockerResult {
+	ctx := context.Background()
 	r := container.Resources{
 		Memory:   d.Config.Memory,
 		NanoCPUs: int64(d.Config.Cpu * math.Pow(10, 9)),
@@ -137,15 +423,21 @@ func (d *Docker) Run() DockerResult {
 
 	cc := container.Config{
 		Image:        d.Config.Image,
+		Cmd:          d.Config.Cmd,
 		Tty:          false,
 		Env:          d.Config.Env,
 		ExposedPorts: d.Config.ExposedPorts,
 	}
 
+	// Containers sharing the network of another one cannot publish ports.
 	hc := container.HostConfig{
-		RestartPolicy:   rp,
 		Resources:       r,
-		PublishAllPorts: true,
+		Mounts:          d.Config.Mounts,
+		NetworkMode:     container.NetworkMode(d.Config.NetworkMode),
+		PublishAllPorts: d.Config.NetworkMode == "",
+	}
+	if d.Config.NetworkMode != "" {
+		cc.ExposedPorts = nil
 	}
 
 	resp, err := d.Client.ContainerCreate(ctx, &cc, &hc, nil, nil, d.Config.Name)
@@ -154,21 +446,13 @@ func (d *Docker) Run() DockerResult {
 		return DockerResult{Error: err}
 	}
 
-	err = d.Client.ContainerStart(ctx, d.Config.Name, container.StartOptions{})
+	err = d.Client.ContainerStart(ctx, resp.ID, container.StartOptions{})
 	if err != nil {
 		log.Printf("Error starting container %s: %v\n", d.Config.Name, err)
 		return DockerResult{Error: err}
 	}
 
 	d.Config.ContainerID = resp.ID
-
-	out, err := d.Client.ContainerLogs(ctx, resp.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
-	if err != nil {
-		log.Printf("Error getting logs for container %s: %v\n", resp.ID, err)
-		return DockerResult{Error: err}
-	}
-
-	stdcopy.StdCopy(os.Stdout, os.Stderr, out)
 	return DockerResult{
 		ContainerId: resp.ID,
 		Action:      "start",
@@ -177,10 +461,33 @@ func (d *Docker) Run() DockerResult {
 	}
 }
 
+func (d *Docker) Pause(id string) DockerResult {
+	err := d.Client.ContainerPause(context.Background(), id)
+	if err != nil {
+		log.Printf("Error pausing container %s: %v\n", id, err)
+		return DockerResult{Error: err}
+	}
+
+	return DockerResult{Action: "pause", Result: "success"}
+}
+
+func (d *Docker) Unpause(id string) DockerResult {
+	err := d.Client.ContainerUnpause(context.Background(), id)
+	if err != nil {
+		log.Printf("Error unpausing container %s: %v\n", id, err)
+		return DockerResult{Error: err}
+	}
+
+	return DockerResult{Action: "unpause", Result: "success"}
+}
+
+// Stop sends the container its stop signal, SIGTERM unless configured
+// otherwise, kills it once the stop timeout has passed and removes it.
 func (d *Docker) Stop(id string) DockerResult {
 	log.Printf("Attempting to stop container %s", id)
 	ctx := context.Background()
-	err := d.Client.ContainerStop(ctx, id, container.StopOptions{})
+	timeout := int(d.Config.StopTimeout.Seconds())
+	err := d.Client.ContainerStop(ctx, id, container.StopOptions{Signal: d.Config.StopSignal, Timeout: &timeout})
 	if err != nil {
 		log.Printf("Error stopping container %s; %v\n", id, err)
 		return DockerResult{Error: err}
@@ -199,6 +506,51 @@ func (d *Docker) Stop(id string) DockerResult {
 	return DockerResult{Action: "stop", Result: "success", Error: nil}
 }
 
+// Wait waits for the container to exit, or for ctx to be done, and returns
+// its exit code.
+func (d *Docker) Wait(ctx context.Context, id string) (int64, error) {
+	statusCh, errCh := d.Client.ContainerWait(ctx, id, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		log.Printf("Error waiting for container %s: %v\n", id, err)
+		return 0, err
+	case status := <-statusCh:
+		if status.Error != nil {
+			return status.StatusCode, fmt.Errorf("%s", status.Error.Message)
+		}
+		return status.StatusCode, nil
+	}
+}
+
+func (d *Docker) RemoveVolume(name string) error {
+	err := d.Client.VolumeRemove(context.Background(), name, false)
+	if err != nil {
+		log.Printf("Error removing volume %s: %v\n", name, err)
+	}
+
+	return err
+}
+
+// Outputs reads the outputs reported by the container from the tail of its
+// standard output.
+func (d *Docker) Outputs(containerID string) (map[string]string, error) {
+	ctx := context.Background()
+	out, err := d.Client.ContainerLogs(ctx, containerID, container.LogsOptions{ShowStdout: true, Tail: "200"})
+	if err != nil {
+		log.Printf("Error getting logs for container %s: %v\n", containerID, err)
+		return nil, err
+	}
+	defer out.Close()
+
+	var stdout bytes.Buffer
+	_, err = stdcopy.StdCopy(&stdout, io.Discard, out)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseOutputs(&stdout), nil
+}
+
 func (d *Docker) Inspect(containerID string) DockerInspectResponse {
 	dc, _ := client.NewClientWithOpts(client.FromEnv)
 	ctx := context.Background()
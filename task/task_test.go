@@ -0,0 +1,37 @@
+package task
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOutputs(t *testing.T) {
+	tests := []struct {
+		name   string
+		stdout string
+		want   map[string]string
+	}{
+		{"none", "hello\nworld\n", map[string]string{}},
+		{"single", "::set-output digest=sha256:abc\n", map[string]string{"digest": "sha256:abc"}},
+		{"mixed with logs", "building\n::set-output a=1\ndone\n  ::set-output b=2  \n", map[string]string{"a": "1", "b": "2"}},
+		{"later value wins", "::set-output a=1\n::set-output a=2\n", map[string]string{"a": "2"}},
+		{"value with equals sign", "::set-output query=x=y\n", map[string]string{"query": "x=y"}},
+		{"empty value", "::set-output a=\n", map[string]string{"a": ""}},
+		{"malformed", "::set-output a\n::set-output =1\n::set-outputa=1\n", map[string]string{}},
+		{"over the size limit", "::set-output big=" + strings.Repeat("x", MaxOutputSize) + "\n::set-output small=1\n", map[string]string{"small": "1"}},
+	}
+
+	for _, tt := range tests {
+		got := ParseOutputs(strings.NewReader(tt.stdout))
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: ParseOutputs() = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("%s: ParseOutputs() = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
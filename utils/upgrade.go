@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// Stream is a connection taken over from HTTP after an upgrade. Reads go
+// through Reader, which holds whatever was buffered past the HTTP headers.
+type Stream struct {
+	Conn   net.Conn
+	Reader io.Reader
+}
+
+func (s Stream) Write(p []byte) (int, error) {
+	return s.Conn.Write(p)
+}
+
+func (s Stream) Read(p []byte) (int, error) {
+	return s.Reader.Read(p)
+}
+
+// CloseWrite tells the other end there is nothing more to read, while the
+// stream can still be read from.
+func (s Stream) CloseWrite() error {
+	if cw, ok := s.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+
+	return nil
+}
+
+func (s Stream) Close() error {
+	return s.Conn.Close()
+}
+
+// IsUpgrade tells whether the request asks to upgrade to a raw TCP stream,
+// the way Docker attaches to containers.
+func IsUpgrade(r *http.Request) bool {
+	return r.Header.Get("Connection") == "Upgrade" && r.Header.Get("Upgrade") == "tcp"
+}
+
+// Upgrade answers an upgrade request with 101 Switching Protocols and the
+// given headers, and takes over its connection.
+func Upgrade(w http.ResponseWriter, header http.Header) (Stream, error) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return Stream{}, fmt.Errorf("connection cannot be upgraded")
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return Stream{}, err
+	}
+
+	fmt.Fprintf(buf, "HTTP/1.1 101 UPGRADED\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n")
+	header.Write(buf)
+	fmt.Fprintf(buf, "\r\n")
+	err = buf.Flush()
+	if err != nil {
+		conn.Close()
+		return Stream{}, err
+	}
+
+	return Stream{Conn: conn, Reader: buf.Reader}, nil
+}
+
+// Splice copies one stream to the other both ways. Input from the client
+// running out is passed on to the backend, and the backend running out of
+// output ends both streams.
+func Splice(client Stream, backend Stream) {
+	go func() {
+		io.Copy(backend, client)
+		backend.CloseWrite()
+	}()
+
+	io.Copy(client, backend)
+	client.Close()
+	backend.Close()
+}
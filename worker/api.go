@@ -21,6 +21,17 @@ func (a *Api) initRouter() {
 		r.Get("/", a.GetTasksHandler)
 		r.Route("/{taskID}", func(r chi.Router) {
 			r.Delete("/", a.StopTaskHandler)
+			r.Get("/logs", a.GetTaskLogsHandler)
+			r.Get("/archive", a.GetTaskArchiveHandler)
+			r.Put("/archive", a.PutTaskArchiveHandler)
+			r.Post("/pause", a.PauseTaskHandler)
+			r.Post("/resume", a.ResumeTaskHandler)
+			r.Post("/remove", a.RemoveTaskHandler)
+			r.Post("/exec", a.ExecTaskHandler)
+			r.Route("/exec/{execID}", func(r chi.Router) {
+				r.Get("/", a.GetExecHandler)
+				r.Post("/resize", a.ResizeExecHandler)
+			})
 		})
 	})
 	a.Router.Route("/stats", func(r chi.Router) {
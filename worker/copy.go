@@ -0,0 +1,46 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/d-bolshakov/orchestrator/task"
+)
+
+// copyFromTask returns a tar archive of a file or directory in a container
+// of the task.
+func (w *Worker) copyFromTask(ctx context.Context, t *task.Task, name string, srcPath string) (io.ReadCloser, error) {
+	containerID, err := containerByName(t, name)
+	if err != nil {
+		return nil, err
+	}
+
+	d := task.NewDocker(task.NewConfig(t))
+	return d.CopyFrom(ctx, containerID, srcPath)
+}
+
+// copyToTask extracts a tar archive holding a single file or directory to
+// dstPath in a container of the task, the way cp does: into dstPath if it
+// is a directory, and in its place otherwise.
+func (w *Worker) copyToTask(ctx context.Context, t *task.Task, name string, dstPath string, archive io.Reader) error {
+	containerID, err := containerByName(t, name)
+	if err != nil {
+		return err
+	}
+
+	d := task.NewDocker(task.NewConfig(t))
+	isDir, err := d.IsDir(ctx, containerID, dstPath)
+	if err == nil && isDir {
+		return d.CopyTo(ctx, containerID, dstPath, archive)
+	}
+	if strings.HasSuffix(dstPath, "/") {
+		return fmt.Errorf("directory %s does not exist", dstPath)
+	}
+
+	renamed := task.RenameArchiveRoot(archive, path.Base(dstPath))
+	defer renamed.Close()
+	return d.CopyTo(ctx, containerID, path.Dir(dstPath), renamed)
+}
@@ -0,0 +1,47 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/d-bolshakov/orchestrator/utils"
+)
+
+// execTask starts a command in a container of a running task and returns
+// the ID of the command along with the stream attached to it.
+func (w *Worker) execTask(ctx context.Context, t *task.Task, opts task.ExecOptions) (string, utils.Stream, error) {
+	if t.State != task.Running {
+		return "", utils.Stream{}, fmt.Errorf("task %s is %v, commands only run in running tasks", t.ID, t.State)
+	}
+	containerID, err := containerByName(t, opts.Container)
+	if err != nil {
+		return "", utils.Stream{}, err
+	}
+
+	d := task.NewDocker(task.NewConfig(t))
+	execID, hijacked, err := d.Exec(ctx, containerID, opts)
+	if err != nil {
+		return "", utils.Stream{}, err
+	}
+
+	return execID, utils.Stream{Conn: hijacked.Conn, Reader: hijacked.Reader}, nil
+}
+
+// taskExec returns the status of a command, as long as it was started in
+// one of the containers of the task.
+func (w *Worker) taskExec(ctx context.Context, t *task.Task, execID string) (task.ExecStatus, error) {
+	d := task.NewDocker(task.NewConfig(t))
+	status, err := d.InspectExec(ctx, execID)
+	if err != nil {
+		return status, err
+	}
+
+	for _, id := range append([]string{t.ContainerID}, t.SidecarContainerIDs...) {
+		if id != "" && id == status.ContainerID {
+			return status, nil
+		}
+	}
+
+	return task.ExecStatus{}, fmt.Errorf("no command %s in task %s", execID, t.ID)
+}
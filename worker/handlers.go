@@ -1,12 +1,15 @@
 package worker
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 
 	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/d-bolshakov/orchestrator/utils"
 	"github.com/go-chi/chi"
 	"github.com/google/uuid"
 )
@@ -42,6 +45,9 @@ func (a *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *Api) GetTasksHandler(w http.ResponseWriter, r *http.Request) {
+	a.Worker.mu.Lock()
+	defer a.Worker.mu.Unlock()
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 	json.NewEncoder(w).Encode(a.Worker.GetTasks())
@@ -57,7 +63,7 @@ func (a *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
 
 	tID, _ := uuid.Parse(taskID)
 
-	taskToStop, err := a.Worker.Db.Get(tID.String())
+	taskToStop, err := a.Worker.GetTask(tID.String())
 	if err != nil {
 		// TODO: add better error handling
 		log.Printf("No task with ID %v found", tID)
@@ -72,7 +78,327 @@ func (a *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(204)
 }
 
+// GetTaskLogsHandler streams the logs of the container of a task, as plain
+// text with stdout and stderr interleaved. The logs of removed containers are
+// served from the log files of the worker.
+func (a *Api) GetTaskLogsHandler(w http.ResponseWriter, r *http.Request) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		log.Printf("Invalid taskID passed in request: %v\n", err)
+		w.WriteHeader(400)
+		return
+	}
+
+	t, err := a.Worker.GetTask(tID.String())
+	if err != nil {
+		log.Printf("No task with ID %v found", tID)
+		w.WriteHeader(404)
+		return
+	}
+
+	opts, err := task.ParseLogOptions(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        err.Error(),
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	logs, err := a.Worker.openLogs(r.Context(), t, opts)
+	if err != nil {
+		msg := fmt.Sprintf("Error reading logs of task %s: %v", tID, err)
+		log.Println(msg)
+		w.WriteHeader(404)
+		e := ErrResponse{
+			HTTPStatusCode: 404,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+	defer logs.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(200)
+	io.Copy(utils.FlushWriter{W: w}, logs)
+}
+
+func (a *Api) ExecTaskHandler(w http.ResponseWriter, r *http.Request) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		log.Printf("Invalid taskID passed in request: %v\n", err)
+		w.WriteHeader(400)
+		return
+	}
+
+	t, err := a.Worker.GetTask(tID.String())
+	if err != nil {
+		log.Printf("No task with ID %v found", tID)
+		w.WriteHeader(404)
+		return
+	}
+
+	opts, err := task.ParseExecOptions(r.URL.Query())
+	if err == nil && !utils.IsUpgrade(r) {
+		err = fmt.Errorf("exec needs the connection to be upgraded to tcp")
+	}
+	if err != nil {
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        err.Error(),
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	execID, backend, err := a.Worker.execTask(context.Background(), t, opts)
+	if err != nil {
+		msg := fmt.Sprintf("Error running command in task %s: %v", tID, err)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	stream, err := utils.Upgrade(w, http.Header{"X-Exec-Id": {execID}})
+	if err != nil {
+		log.Printf("Error upgrading connection for command %s: %v\n", execID, err)
+		backend.Close()
+		return
+	}
+
+	log.Printf("Running command %s in task %s: %v\n", execID, tID, opts.Cmd)
+	utils.Splice(stream, backend)
+	log.Printf("Command %s in task %s finished\n", execID, tID)
+}
+
+func (a *Api) ResizeExecHandler(w http.ResponseWriter, r *http.Request) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		log.Printf("Invalid taskID passed in request: %v\n", err)
+		w.WriteHeader(400)
+		return
+	}
+
+	t, err := a.Worker.GetTask(tID.String())
+	if err != nil {
+		log.Printf("No task with ID %v found", tID)
+		w.WriteHeader(404)
+		return
+	}
+
+	height, width, err := task.ParseTerminalSize(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        err.Error(),
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	execID := chi.URLParam(r, "execID")
+	_, err = a.Worker.taskExec(r.Context(), t, execID)
+	if err == nil {
+		err = task.NewDocker(task.NewConfig(t)).ResizeExec(r.Context(), execID, height, width)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error resizing terminal of command %s: %v", execID, err)
+		log.Println(msg)
+		w.WriteHeader(404)
+		e := ErrResponse{
+			HTTPStatusCode: 404,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (a *Api) GetExecHandler(w http.ResponseWriter, r *http.Request) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		log.Printf("Invalid taskID passed in request: %v\n", err)
+		w.WriteHeader(400)
+		return
+	}
+
+	t, err := a.Worker.GetTask(tID.String())
+	if err != nil {
+		log.Printf("No task with ID %v found", tID)
+		w.WriteHeader(404)
+		return
+	}
+
+	execID := chi.URLParam(r, "execID")
+	status, err := a.Worker.taskExec(r.Context(), t, execID)
+	if err != nil {
+		msg := fmt.Sprintf("Error retrieving command %s: %v", execID, err)
+		log.Println(msg)
+		w.WriteHeader(404)
+		e := ErrResponse{
+			HTTPStatusCode: 404,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(status)
+}
+
+func (a *Api) GetTaskArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		log.Printf("Invalid taskID passed in request: %v\n", err)
+		w.WriteHeader(400)
+		return
+	}
+
+	t, err := a.Worker.GetTask(tID.String())
+	if err != nil {
+		log.Printf("No task with ID %v found", tID)
+		w.WriteHeader(404)
+		return
+	}
+
+	srcPath := r.URL.Query().Get("path")
+	if srcPath == "" {
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        "no path to copy",
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	archive, err := a.Worker.copyFromTask(r.Context(), t, r.URL.Query().Get("container"), srcPath)
+	if err != nil {
+		msg := fmt.Sprintf("Error copying %s from task %s: %v", srcPath, tID, err)
+		log.Println(msg)
+		w.WriteHeader(404)
+		e := ErrResponse{
+			HTTPStatusCode: 404,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.WriteHeader(200)
+	io.Copy(w, archive)
+}
+
+func (a *Api) PutTaskArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		log.Printf("Invalid taskID passed in request: %v\n", err)
+		w.WriteHeader(400)
+		return
+	}
+
+	t, err := a.Worker.GetTask(tID.String())
+	if err != nil {
+		log.Printf("No task with ID %v found", tID)
+		w.WriteHeader(404)
+		return
+	}
+
+	dstPath := r.URL.Query().Get("path")
+	if dstPath == "" {
+		w.WriteHeader(400)
+		e := ErrResponse{
+			HTTPStatusCode: 400,
+			Message:        "no path to copy to",
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	err = a.Worker.copyToTask(r.Context(), t, r.URL.Query().Get("container"), dstPath, r.Body)
+	if err != nil {
+		msg := fmt.Sprintf("Error copying to %s in task %s: %v", dstPath, tID, err)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	log.Printf("Copied files to %s in task %s\n", dstPath, tID)
+	w.WriteHeader(204)
+}
+
+func (a *Api) PauseTaskHandler(w http.ResponseWriter, r *http.Request) {
+	a.taskAction(w, r, "pause", a.Worker.PauseTask)
+}
+
+func (a *Api) ResumeTaskHandler(w http.ResponseWriter, r *http.Request) {
+	a.taskAction(w, r, "resume", a.Worker.ResumeTask)
+}
+
+func (a *Api) RemoveTaskHandler(w http.ResponseWriter, r *http.Request) {
+	a.taskAction(w, r, "remove", a.Worker.RemoveTask)
+}
+
+func (a *Api) taskAction(w http.ResponseWriter, r *http.Request, action string, do func(id string) error) {
+	tID, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		log.Printf("Invalid taskID passed in request: %v\n", err)
+		w.WriteHeader(400)
+		return
+	}
+
+	a.Worker.mu.Lock()
+	defer a.Worker.mu.Unlock()
+
+	_, err = a.Worker.Db.Get(tID.String())
+	if err != nil {
+		log.Printf("No task with ID %v found", tID)
+		w.WriteHeader(404)
+		return
+	}
+
+	err = do(tID.String())
+	if err != nil {
+		msg := fmt.Sprintf("Error trying to %s task %s: %v", action, tID, err)
+		log.Println(msg)
+		w.WriteHeader(409)
+		e := ErrResponse{
+			HTTPStatusCode: 409,
+			Message:        msg,
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	log.Printf("Task %s: %s done\n", tID, action)
+	w.WriteHeader(204)
+}
+
 func (a *Api) GetStatsHandler(w http.ResponseWriter, r *http.Request) {
+	a.Worker.mu.Lock()
+	defer a.Worker.mu.Unlock()
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(200)
 	json.NewEncoder(w).Encode(a.Worker.Stats)
@@ -0,0 +1,325 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/logsink"
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// logPath returns the file the logs of a container of the task are written
+// to, named after the container. Rotated files get a numbered suffix, .1
+// being the most recent.
+func (w *Worker) logPath(taskID string, name string) string {
+	return filepath.Join(w.LogDir, taskID, name+".log")
+}
+
+// captureLogs copies the logs of a container to its log file until the
+// container stops, so that they outlive the container. Lines are kept with
+// their Docker timestamps, and handed to the log sinks, if any.
+func (w *Worker) captureLogs(t task.Task, name string, containerID string) {
+	taskID := t.ID.String()
+	path := w.logPath(taskID, name)
+	f, err := openLogFile(path, w.LogMaxSize, w.LogMaxFiles)
+	if err != nil {
+		log.Printf("Error opening log file %s: %v\n", path, err)
+		return
+	}
+	defer f.Close()
+
+	d := task.NewDocker(&task.Config{})
+	logs, err := d.Logs(context.Background(), containerID, task.LogOptions{Follow: true, Timestamps: true})
+	if err != nil {
+		log.Printf("Error capturing logs of container %s: %v\n", containerID, err)
+		return
+	}
+	defer logs.Close()
+
+	var stdout, stderr io.Writer = f, f
+	if w.Shipper != nil {
+		source := logsink.Source{
+			TaskID:    taskID,
+			TaskName:  t.Name,
+			Container: name,
+			Node:      w.Name,
+			Labels:    t.Labels,
+		}
+		stdout = io.MultiWriter(f, &shipWriter{shipper: w.Shipper, source: source, stream: "stdout"})
+		stderr = io.MultiWriter(f, &shipWriter{shipper: w.Shipper, source: source, stream: "stderr"})
+	}
+
+	_, err = stdcopy.StdCopy(stdout, stderr, logs)
+	if err != nil {
+		log.Printf("Error capturing logs of container %s: %v\n", containerID, err)
+	}
+}
+
+// shipWriter splits the timestamped logs of a container into lines and
+// ships each of them as an entry.
+type shipWriter struct {
+	shipper *logsink.Shipper
+	source  logsink.Source
+	stream  string
+	partial []byte
+}
+
+func (s *shipWriter) Write(p []byte) (int, error) {
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(s.partial[:i])
+		s.partial = s.partial[i+1:]
+
+		timestamp, text, _ := strings.Cut(line, " ")
+		ts, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			ts = time.Now().UTC()
+			text = line
+		}
+		s.shipper.Ship(logsink.Entry{Source: s.source, Time: ts, Stream: s.stream, Line: text})
+	}
+
+	return len(p), nil
+}
+
+// openLogs returns the logs of a container of the task, as plain text. They
+// are streamed from Docker while the container exists, and read from its log
+// files once it has been removed.
+func (w *Worker) openLogs(ctx context.Context, t *task.Task, opts task.LogOptions) (io.ReadCloser, error) {
+	containerID, err := containerByName(t, opts.Container)
+	if err == nil {
+		d := task.NewDocker(task.NewConfig(t))
+		logs, err := d.Logs(ctx, containerID, opts)
+		if err == nil {
+			r, pw := io.Pipe()
+			go func() {
+				_, err := stdcopy.StdCopy(pw, pw, logs)
+				logs.Close()
+				pw.CloseWithError(err)
+			}()
+			return r, nil
+		}
+	}
+
+	name := opts.Container
+	if name == "" {
+		name = task.MainContainer
+	}
+	logs, err := w.readLogFiles(t.ID.String(), name, opts)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(logs), nil
+}
+
+// logFile is a log file that is rotated once it grows past maxSize, keeping
+// at most maxFiles rotated files.
+type logFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func openLogFile(path string, maxSize int64, maxFiles int) (*logFile, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &logFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	err = l.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (l *logFile) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		err := l.rotate()
+		if err != nil {
+			log.Printf("Error rotating log file %s: %v\n", l.path, err)
+		}
+	}
+
+	n, err := l.file.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the rotated files up by one, dropping the oldest, and starts
+// a new file.
+func (l *logFile) rotate() error {
+	l.file.Close()
+
+	os.Remove(fmt.Sprintf("%s.%d", l.path, l.maxFiles))
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	if l.maxFiles > 0 {
+		os.Rename(l.path, l.path+".1")
+	} else {
+		os.Remove(l.path)
+	}
+
+	return l.open()
+}
+
+func (l *logFile) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.file.Close()
+}
+
+// readLogFiles returns the logs of a container kept in its log files, oldest
+// first, selected by the options like Docker would.
+func (w *Worker) readLogFiles(taskID string, name string, opts task.LogOptions) (io.Reader, error) {
+	if name != filepath.Base(name) || name == ".." {
+		return nil, fmt.Errorf("invalid container name %q", name)
+	}
+
+	path := w.logPath(taskID, name)
+	paths := []string{}
+	for i := w.LogMaxFiles; i >= 1; i-- {
+		paths = append(paths, fmt.Sprintf("%s.%d", path, i))
+	}
+	paths = append(paths, path)
+
+	var since time.Time
+	if opts.Since != "" {
+		d, err := time.ParseDuration(opts.Since)
+		if err == nil {
+			since = time.Now().Add(-d)
+		} else {
+			since, _ = time.Parse(time.RFC3339, opts.Since)
+		}
+	}
+
+	found := false
+	lines := []string{}
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			continue
+		}
+		found = true
+
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			timestamp, text, _ := strings.Cut(line, " ")
+			if !since.IsZero() {
+				ts, err := time.Parse(time.RFC3339Nano, timestamp)
+				if err == nil && ts.Before(since) {
+					continue
+				}
+			}
+			if !opts.Timestamps {
+				line = text
+			}
+			lines = append(lines, line)
+		}
+		f.Close()
+	}
+	if !found {
+		return nil, fmt.Errorf("no logs kept for container %s of task %s", name, taskID)
+	}
+
+	if opts.Tail != "" && opts.Tail != "all" {
+		n, _ := strconv.Atoi(opts.Tail)
+		if n < len(lines) {
+			lines = lines[len(lines)-n:]
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return &buf, nil
+}
+
+// pruneLogs removes the log files of tasks that are no longer running and
+// haven't been written to for LogRetention.
+func (w *Worker) pruneLogs() {
+	entries, err := os.ReadDir(w.LogDir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		t, err := w.GetTask(e.Name())
+		if err == nil && (t.State == task.Running || t.State == task.Paused || t.State == task.Unknown) {
+			continue
+		}
+
+		dir := filepath.Join(w.LogDir, e.Name())
+		if time.Since(lastModified(dir)) < w.LogRetention {
+			continue
+		}
+
+		log.Printf("Removing logs of task %s\n", e.Name())
+		err = os.RemoveAll(dir)
+		if err != nil {
+			log.Printf("Error removing logs of task %s: %v\n", e.Name(), err)
+		}
+	}
+}
+
+func lastModified(dir string) time.Time {
+	var last time.Time
+	files, _ := os.ReadDir(dir)
+	for _, f := range files {
+		info, err := f.Info()
+		if err == nil && info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+
+	return last
+}
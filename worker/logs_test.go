@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/d-bolshakov/orchestrator/task"
+)
+
+func TestLogFileRotation(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxSize  int64
+		maxFiles int
+		lines    int
+		// Contents of the log file and its rotated files, newest first.
+		want []string
+	}{
+		{"no limit", 0, 2, 4, []string{"1 2 3 4"}},
+		{"under the limit", 100, 2, 4, []string{"1 2 3 4"}},
+		{"rotated", 25, 2, 4, []string{"3 4", "1 2"}},
+		{"oldest dropped", 25, 2, 7, []string{"7", "5 6", "3 4"}},
+		{"no rotated files kept", 25, 0, 7, []string{"7"}},
+		{"line over the limit", 5, 3, 2, []string{"2", "1"}},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "task", "main.log")
+		f, err := openLogFile(path, tt.maxSize, tt.maxFiles)
+		if err != nil {
+			t.Fatalf("%s: openLogFile() failed: %v", tt.name, err)
+		}
+		for i := 1; i <= tt.lines; i++ {
+			fmt.Fprintf(f, "line-%04d\n", i)
+		}
+		f.Close()
+
+		for i := 0; i <= tt.maxFiles+1; i++ {
+			p := path
+			if i > 0 {
+				p = fmt.Sprintf("%s.%d", path, i)
+			}
+
+			data, err := os.ReadFile(p)
+			if i >= len(tt.want) {
+				if err == nil {
+					t.Errorf("%s: %s exists, want it gone", tt.name, filepath.Base(p))
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: reading %s failed: %v", tt.name, filepath.Base(p), err)
+				continue
+			}
+
+			got := []string{}
+			for _, line := range strings.Fields(string(data)) {
+				got = append(got, strings.TrimLeft(strings.TrimPrefix(line, "line-"), "0"))
+			}
+			if strings.Join(got, " ") != tt.want[i] {
+				t.Errorf("%s: %s has lines %v, want %s", tt.name, filepath.Base(p), got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestReadLogFiles(t *testing.T) {
+	w := &Worker{LogDir: t.TempDir(), LogMaxSize: 70, LogMaxFiles: 2}
+	f, err := openLogFile(w.logPath("task", "main"), w.LogMaxSize, w.LogMaxFiles)
+	if err != nil {
+		t.Fatalf("openLogFile() failed: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(f, "2024-01-15T10:30:0%dZ line %d\n", i, i)
+	}
+	f.Close()
+
+	tests := []struct {
+		name string
+		opts task.LogOptions
+		want string
+	}{
+		{"all", task.LogOptions{}, "line 1\nline 2\nline 3\nline 4\nline 5\n"},
+		{"tail", task.LogOptions{Tail: "2"}, "line 4\nline 5\n"},
+		{"timestamps", task.LogOptions{Tail: "1", Timestamps: true}, "2024-01-15T10:30:05Z line 5\n"},
+		{"since", task.LogOptions{Since: "2024-01-15T10:30:04Z"}, "line 4\nline 5\n"},
+	}
+
+	for _, tt := range tests {
+		r, err := w.readLogFiles("task", "main", tt.opts)
+		if err != nil {
+			t.Errorf("%s: readLogFiles() failed: %v", tt.name, err)
+			continue
+		}
+
+		got, _ := io.ReadAll(r)
+		if string(got) != tt.want {
+			t.Errorf("%s: readLogFiles() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	_, err = w.readLogFiles("task", "sidecar", task.LogOptions{})
+	if err == nil {
+		t.Errorf("readLogFiles() of a container without logs succeeded, want an error")
+	}
+}
@@ -0,0 +1,165 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/docker/docker/api/types/container"
+)
+
+// pullImages pulls the images of all the containers of the task.
+func (w *Worker) pullImages(t *task.Task) task.DockerResult {
+	d := task.NewDocker(task.NewConfig(t))
+	result := task.DockerResult{Action: "pull", Result: "success"}
+	for _, image := range t.Images() {
+		result = d.PullImage(image)
+		if result.Error != nil {
+			return result
+		}
+	}
+
+	return result
+}
+
+// InitContainerTimeout bounds how long the init containers of a task without
+// an active deadline may run.
+const InitContainerTimeout = 10 * time.Minute
+
+// runInitContainers runs the init containers of the task one after the
+// other, each to completion, and removes them. It stops at the first one
+// that fails. They all have to finish before the task's active deadline, or
+// within InitContainerTimeout if it has none; one that doesn't is stopped.
+func (w *Worker) runInitContainers(t *task.Task) error {
+	deadline, ok := t.ActiveDeadline()
+	if !ok {
+		deadline = time.Now().UTC().Add(InitContainerTimeout)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	for _, c := range t.InitContainers {
+		d := task.NewDocker(task.NewContainerConfig(t, c, ""))
+		result := d.Start()
+		if result.Error != nil {
+			return fmt.Errorf("init container %s failed to start: %v", c.Name, result.Error)
+		}
+
+		exitCode, err := d.Wait(ctx, result.ContainerId)
+		if ctx.Err() != nil {
+			// captureLogs follows the logs until the container exits, so
+			// those of a hung init container aren't kept.
+			d.Stop(result.ContainerId)
+			return fmt.Errorf("init container %s did not finish by %s", c.Name, deadline.Format(time.RFC3339))
+		}
+		w.captureLogs(*t, c.Name, result.ContainerId)
+		d.Stop(result.ContainerId)
+		if err != nil {
+			return fmt.Errorf("init container %s failed: %v", c.Name, err)
+		}
+		if exitCode != 0 {
+			return fmt.Errorf("init container %s exited with code %d", c.Name, exitCode)
+		}
+		log.Printf("Init container %s of task %s completed\n", c.Name, t.ID)
+	}
+
+	return nil
+}
+
+// startSidecars starts the sidecars of the task in the network namespace of
+// its main container. Sidecars that did start are stopped again if one of
+// them fails to.
+func (w *Worker) startSidecars(t *task.Task) error {
+	t.SidecarContainerIDs = nil
+	for _, c := range t.Sidecars {
+		d := task.NewDocker(task.NewContainerConfig(t, c, t.ContainerID))
+		result := d.Start()
+		if result.Error != nil {
+			w.stopSidecars(t)
+			return fmt.Errorf("sidecar %s failed to start: %v", c.Name, result.Error)
+		}
+		t.SidecarContainerIDs = append(t.SidecarContainerIDs, result.ContainerId)
+		go w.captureLogs(*t, c.Name, result.ContainerId)
+	}
+
+	return nil
+}
+
+// stopSidecars stops and removes the sidecars of the task.
+func (w *Worker) stopSidecars(t *task.Task) {
+	d := task.NewDocker(task.NewConfig(t))
+	for _, id := range t.SidecarContainerIDs {
+		result := d.Stop(id)
+		if result.Error != nil {
+			log.Printf("Error stopping sidecar %s of task %s: %v\n", id, t.ID, result.Error)
+		}
+	}
+	t.SidecarContainerIDs = nil
+}
+
+// removeVolumes removes the volumes of a task whose containers are gone.
+func (w *Worker) removeVolumes(t *task.Task) {
+	d := task.NewDocker(task.NewConfig(t))
+	for _, v := range t.VolumeNames() {
+		d.RemoveVolume(v)
+	}
+}
+
+// pauseSidecars pauses or unpauses the sidecars of the task along with its
+// main container.
+func (w *Worker) pauseSidecars(t *task.Task, pause bool) error {
+	d := task.NewDocker(task.NewConfig(t))
+	for _, id := range t.SidecarContainerIDs {
+		var result task.DockerResult
+		if pause {
+			result = d.Pause(id)
+		} else {
+			result = d.Unpause(id)
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+
+	return nil
+}
+
+// containerByName returns the ID of a container of the task: the sidecar
+// with the given name, or the main container.
+func containerByName(t *task.Task, name string) (string, error) {
+	if name == "" {
+		if t.ContainerID == "" {
+			return "", fmt.Errorf("task %s has no container", t.ID)
+		}
+		return t.ContainerID, nil
+	}
+
+	for i, c := range t.Sidecars {
+		if c.Name == name && i < len(t.SidecarContainerIDs) {
+			return t.SidecarContainerIDs[i], nil
+		}
+	}
+
+	return "", fmt.Errorf("task %s has no sidecar %s", t.ID, name)
+}
+
+// exitedSidecar returns the name and state of the first sidecar of the task
+// that is no longer running, if any.
+func (w *Worker) exitedSidecar(t *task.Task) (string, *container.State) {
+	d := task.NewDocker(task.NewConfig(t))
+	for i, id := range t.SidecarContainerIDs {
+		resp := d.Inspect(id)
+		if resp.Error != nil || resp.Container == nil {
+			continue
+		}
+
+		status := resp.Container.State.Status
+		if status == "exited" || status == "dead" {
+			return t.Sidecars[i].Name, resp.Container.State
+		}
+	}
+
+	return "", nil
+}
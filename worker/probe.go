@@ -0,0 +1,143 @@
+package worker
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/probe"
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/docker/go-connections/nat"
+)
+
+// ProbeTasks runs the probes of the running tasks next to their containers.
+// The results are kept in the tasks, where the manager picks them up along
+// with the rest of the task state.
+func (w *Worker) ProbeTasks() {
+	for {
+		w.probeTasks()
+		time.Sleep(time.Second)
+	}
+}
+
+// The probes run on copies of the tasks, without holding the lock, since
+// they can take a while.
+func (w *Worker) probeTasks() {
+	w.mu.Lock()
+	running := []task.Task{}
+	for _, t := range w.GetTasks() {
+		if t.State == task.Running && t.Kind != task.KindJob {
+			running = append(running, *t)
+		}
+	}
+	w.mu.Unlock()
+
+	for i := range running {
+		t := &running[i]
+		failure := w.runProbes(t)
+
+		// The task may have changed while the probes were running, only its
+		// probe results are ours to update.
+		current, ok := w.updateTask(t.ID.String(), task.Running, func(c *task.Task) {
+			c.StartupStatus = t.StartupStatus
+			c.LivenessStatus = t.LivenessStatus
+			c.ReadinessStatus = t.ReadinessStatus
+			c.Ready = t.Ready
+		})
+		if ok && failure != "" {
+			w.failUnhealthyTask(current, failure)
+		}
+	}
+}
+
+// runProbes runs the probes of the task that are due and updates their
+// results and the readiness of the task. It returns why the task has to be
+// stopped, if a startup or liveness probe failed too many times.
+func (w *Worker) runProbes(t *task.Task) string {
+	now := time.Now().UTC()
+
+	if t.StartupProbe != nil && !t.StartupStatus.Healthy {
+		t.Ready = false
+		p := t.StartupProbe.WithDefaults()
+		w.runProbe(t, p, &t.StartupStatus, "startup", now)
+		if t.StartupStatus.Failed(p) {
+			return fmt.Sprintf("startup probe failed: %s", t.StartupStatus.LastError)
+		}
+		if !t.StartupStatus.Healthy {
+			return ""
+		}
+	}
+
+	if lp := t.Liveness(); lp != nil {
+		p := lp.WithDefaults()
+		w.runProbe(t, p, &t.LivenessStatus, "liveness", now)
+		if t.LivenessStatus.Failed(p) {
+			return fmt.Sprintf("liveness probe failed: %s", t.LivenessStatus.LastError)
+		}
+	}
+
+	if t.ReadinessProbe == nil {
+		t.Ready = true
+		return ""
+	}
+	p := t.ReadinessProbe.WithDefaults()
+	w.runProbe(t, p, &t.ReadinessStatus, "readiness", now)
+	t.Ready = t.ReadinessStatus.Healthy
+
+	return ""
+}
+
+func (w *Worker) runProbe(t *task.Task, p probe.Probe, status *probe.Status, kind string, now time.Time) {
+	if !status.Due(p, t.StartTime, now) {
+		return
+	}
+
+	start := time.Now()
+	err := w.probeTask(t, p)
+	if err != nil {
+		log.Printf("The %s probe of task %s failed: %v\n", kind, t.ID, err)
+	}
+	status.Record(p, err, now, time.Since(start))
+}
+
+// failUnhealthyTask stops the container of a task that failed its probes
+// and marks the task Failed, leaving any restart to the manager.
+func (w *Worker) failUnhealthyTask(t *task.Task, message string) {
+	log.Printf("Stopping task %s: %s\n", t.ID, message)
+	w.failTask(t, true, task.ReasonUnhealthy, message)
+}
+
+// probeTask runs the probe against the container of the task. Network
+// probes go through the port published on this host.
+func (w *Worker) probeTask(t *task.Task, p probe.Probe) error {
+	target := probe.Target{Host: "127.0.0.1", ContainerID: t.ContainerID}
+	if p.Type != probe.TypeExec {
+		hostPort := getHostPort(t.HostPorts, p.Port)
+		if hostPort == "" {
+			return fmt.Errorf("no host port published for port %d of task %s", p.Port, t.ID)
+		}
+		target.Port = hostPort
+	}
+
+	return p.Check(target)
+}
+
+// getHostPort returns the host port bound to the container port, or to any
+// port if the container port is 0.
+func getHostPort(ports nat.PortMap, containerPort int) string {
+	if containerPort == 0 {
+		for _, bindings := range ports {
+			if len(bindings) > 0 {
+				return bindings[0].HostPort
+			}
+		}
+		return ""
+	}
+
+	bindings := ports[nat.Port(fmt.Sprintf("%d/tcp", containerPort))]
+	if len(bindings) == 0 {
+		return ""
+	}
+
+	return bindings[0].HostPort
+}
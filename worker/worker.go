@@ -4,38 +4,86 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
+	"github.com/d-bolshakov/orchestrator/logsink"
+	"github.com/d-bolshakov/orchestrator/probe"
 	"github.com/d-bolshakov/orchestrator/store"
 	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/docker/docker/api/types/container"
 	"github.com/golang-collections/collections/queue"
 )
 
+// Worker runs the tasks it is sent next to its API, its probes and its
+// updates. mu guards the queue, the stats and the task store, which only
+// ever holds copies of the tasks: a task is changed on a copy, and stored
+// again under mu. The lock is never held while a container is started,
+// stopped or probed.
 type Worker struct {
-	Name      string
-	Queue     queue.Queue
-	Db        store.Store[*task.Task]
-	TaskCount int
+	mu sync.Mutex
+
+	Name  string
+	Queue queue.Queue
+	Db    store.Store[*task.Task]
 
 	Stats *Stats
+
+	// The logs of every container are kept in LogDir, in files rotated once
+	// they reach LogMaxSize bytes, keeping LogMaxFiles rotated files. The
+	// logs of a task are removed LogRetention after it stopped writing them.
+	// LogDir defaults to "logs" in the working directory, next to the
+	// persistent task store, so that the logs survive a reboot like it does.
+	LogDir       string
+	LogMaxSize   int64
+	LogMaxFiles  int
+	LogRetention time.Duration
+
+	// Shipper forwards the logs of every container to external sinks, when
+	// set.
+	Shipper *logsink.Shipper
 }
 
 func (w *Worker) CollectStats() {
 	for {
 		log.Println("Collecting stats")
-		w.Stats = GetStats()
-		w.Stats.TaskCount = w.TaskCount
+		stats := GetStats()
+
+		w.mu.Lock()
+		stats.TaskCount = w.runningTasks()
+		w.Stats = stats
+		w.mu.Unlock()
 		time.Sleep(time.Second * 15)
 	}
 }
 
+// runningTasks counts the tasks whose containers are up. It is called with
+// mu held.
+func (w *Worker) runningTasks() int {
+	count := 0
+	for _, t := range w.GetTasks() {
+		switch t.State {
+		case task.Running, task.Paused, task.Unknown, task.Stopping:
+			count++
+		}
+	}
+
+	return count
+}
+
 func (w *Worker) AddTask(t task.Task) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	w.Queue.Enqueue(t)
 }
 
 func (w *Worker) RunTasks() {
 	for {
-		if w.Queue.Len() != 0 {
+		w.mu.Lock()
+		pending := w.Queue.Len()
+		w.mu.Unlock()
+
+		if pending != 0 {
 			result := w.runTask()
 			if result.Error != nil {
 				log.Printf("Error running task: %v\n", result.Error)
@@ -49,7 +97,9 @@ func (w *Worker) RunTasks() {
 }
 
 func (w *Worker) runTask() task.DockerResult {
+	w.mu.Lock()
 	t := w.Queue.Dequeue()
+	w.mu.Unlock()
 	if t == nil {
 		log.Println("No tasks in the queue")
 		return task.DockerResult{
@@ -59,11 +109,11 @@ func (w *Worker) runTask() task.DockerResult {
 
 	taskQueued := t.(task.Task)
 
-	taskPersisted, err := w.Db.Get(taskQueued.ID.String())
+	taskPersisted, err := w.GetTask(taskQueued.ID.String())
 	if err != nil {
 		// TODO: differentiate between the previously non-existent task and error which occurred during the retrieval
 		taskPersisted = &taskQueued
-		err := w.Db.Put(taskQueued.ID.String(), &taskQueued)
+		err := w.putTask(&taskQueued)
 		if err != nil {
 			msg := fmt.Errorf("error storing task %s: %v", taskQueued.ID.String(), err)
 			log.Println(msg)
@@ -78,6 +128,10 @@ func (w *Worker) runTask() task.DockerResult {
 	if task.ValidStateTransition(taskPersisted.State, taskQueued.State) {
 		switch taskQueued.State {
 		case task.Scheduled:
+			if taskPersisted.State == task.Failed && taskPersisted.ContainerID != "" {
+				// A restarted task leaves the container of its failed run behind.
+				w.removeContainer(taskPersisted)
+			}
 			result = w.StartTask(taskQueued)
 
 		case task.Completed:
@@ -93,43 +147,310 @@ func (w *Worker) runTask() task.DockerResult {
 	return result
 }
 
+// StartTask pulls the images of the task and starts its containers: the init
+// containers first, then the main container and its sidecars. The task is
+// Pulling and then Starting while this takes place.
 func (w *Worker) StartTask(t task.Task) task.DockerResult {
 	t.StartTime = time.Now().UTC()
 	config := task.NewConfig(&t)
 	d := task.NewDocker(config)
-	result := d.Run()
+
+	t.SetState(task.Pulling, task.ReasonPullingImage, fmt.Sprintf("pulling image %s", t.Image))
+	w.putTask(&t)
+	result := w.pullImages(&t)
+	if result.Error != nil {
+		log.Printf("Error pulling image of task %s: %v\n", t.ID, result.Error)
+		t.FinishTime = time.Now().UTC()
+		t.SetState(task.Failed, task.ReasonImagePullError, result.Error.Error())
+		w.putTask(&t)
+		return result
+	}
+
+	t.SetState(task.Starting, task.ReasonCreatingContainer, "")
+	w.putTask(&t)
+	err := w.runInitContainers(&t)
+	if err != nil {
+		log.Printf("Error running task %s: %v\n", t.ID, err)
+		w.removeVolumes(&t)
+		t.FinishTime = time.Now().UTC()
+		reason := task.ReasonInitError
+		if deadline, ok := t.ActiveDeadline(); ok && t.FinishTime.After(deadline) {
+			reason = task.ReasonDeadlineExceeded
+		}
+		t.SetState(task.Failed, reason, err.Error())
+		w.putTask(&t)
+		return task.DockerResult{Error: err}
+	}
+
+	result = d.Start()
 	if result.Error != nil {
 		log.Printf("Error running task %s: %v\n", t.ID, result.Error)
-		t.State = task.Failed
-		w.Db.Put(t.ID.String(), &t)
+		w.removeVolumes(&t)
+		t.FinishTime = time.Now().UTC()
+		t.SetState(task.Failed, task.ReasonStartError, result.Error.Error())
+		w.putTask(&t)
 		return result
 	}
 
 	t.ContainerID = result.ContainerId
-	t.State = task.Running
-	w.Db.Put(t.ID.String(), &t)
+	go w.captureLogs(t, task.MainContainer, t.ContainerID)
+
+	// The published ports are looked up right away, so that hooks and
+	// probes can reach the container before the next update of the tasks.
+	resp := w.InspectTask(t)
+	if resp.Error == nil && resp.Container != nil {
+		t.HostPorts = resp.Container.NetworkSettings.NetworkSettingsBase.Ports
+	}
+
+	err = w.startSidecars(&t)
+	if err != nil {
+		log.Printf("Error running task %s: %v\n", t.ID, err)
+		w.removeContainer(&t)
+		t.FinishTime = time.Now().UTC()
+		t.SetState(task.Failed, task.ReasonStartError, err.Error())
+		w.putTask(&t)
+		return task.DockerResult{Error: err}
+	}
+	t.SetState(task.Running, task.ReasonStarted, fmt.Sprintf("started container %s", result.ContainerId))
+	w.putTask(&t)
+
+	if t.PostStart != nil {
+		err := w.runPostStartHook(&t)
+		if err != nil {
+			log.Printf("PostStart hook of task %s failed: %v\n", t.ID, err)
+			w.failTask(&t, false, task.ReasonHookError, fmt.Sprintf("postStart hook failed: %v", err))
+			return task.DockerResult{Error: err}
+		}
+	}
 
-	w.TaskCount++
 	return result
 }
 
-func (w *Worker) StopTask(t task.Task) task.DockerResult {
-	config := task.NewConfig(&t)
+// runPostStartHook runs the PostStart hook of a task whose container has just
+// started.
+func (w *Worker) runPostStartHook(t *task.Task) error {
+	return w.probeTask(t, *t.PostStart.Probe())
+}
+
+// stopContainer runs the PreStop hook of the task, if asked to, and stops its
+// container gracefully. The task is Stopping while this takes place, for the
+// given reason. Nothing is done, and false is returned, if the stored task
+// is no longer in the state of t, since whatever changed it is taking care
+// of its container.
+func (w *Worker) stopContainer(t *task.Task, preStop bool, reason string, message string) (task.DockerResult, bool) {
+	from := t.State
+	current, ok := w.updateTask(t.ID.String(), from, func(c *task.Task) {
+		c.SetState(task.Stopping, reason, message)
+		c.Ready = false
+	})
+	if !ok {
+		return task.DockerResult{}, false
+	}
+	*t = *current
+
+	config := task.NewConfig(t)
 	d := task.NewDocker(config)
 
-	stopResult := d.Stop(t.ContainerID)
+	// A frozen container cannot handle its stop signal.
+	if from == task.Paused {
+		d.Unpause(t.ContainerID)
+		w.pauseSidecars(t, false)
+	}
+
+	if preStop && t.PreStop != nil {
+		err := w.probeTask(t, *t.PreStop.Probe())
+		if err != nil {
+			log.Printf("PreStop hook of task %s failed: %v\n", t.ID, err)
+		}
+	}
+
+	w.stopSidecars(t)
+	result := d.Stop(t.ContainerID)
+	w.removeVolumes(t)
+	return result, true
+}
+
+// failTask stops the container of a task that cannot go on and marks the
+// task Failed, leaving any restart to the manager. Only the first path to
+// stop a task records how it ended.
+func (w *Worker) failTask(t *task.Task, preStop bool, reason string, message string) {
+	result, ok := w.stopContainer(t, preStop, reason, message)
+	if !ok {
+		return
+	}
+	if result.Error != nil {
+		log.Printf("Error stopping container %s of task %s: %v\n", t.ContainerID, t.ID, result.Error)
+	}
+
+	w.updateTask(t.ID.String(), task.Stopping, func(c *task.Task) {
+		c.Ready = false
+		c.FinishTime = time.Now().UTC()
+		c.SetState(task.Failed, reason, message)
+	})
+}
+
+// putTask stores a copy of the task.
+func (w *Worker) putTask(t *task.Task) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	c := *t
+	return w.Db.Put(t.ID.String(), &c)
+}
+
+// updateTask changes the stored task, if it is still in the given state,
+// and returns a copy of the result. The change is made to the task as it is
+// stored, so that it doesn't undo what other paths stored in the meantime,
+// and it mustn't wait on anything.
+func (w *Worker) updateTask(id string, from task.State, change func(t *task.Task)) (*task.Task, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	t, err := w.Db.Get(id)
+	if err != nil || t.State != from {
+		return nil, false
+	}
+	c := *t
+	change(&c)
+	w.Db.Put(id, &c)
+
+	result := c
+	return &result, true
+}
+
+// PauseTask freezes the container of a running task, which keeps its memory
+// but gets no CPU time until it is resumed. It is called with mu held.
+func (w *Worker) PauseTask(id string) error {
+	t, err := w.Db.Get(id)
+	if err != nil {
+		return err
+	}
+	if !task.ValidStateTransition(t.State, task.Paused) {
+		return fmt.Errorf("task %s is %v and cannot be paused", id, t.State)
+	}
+
+	config := task.NewConfig(t)
+	d := task.NewDocker(config)
+	result := d.Pause(t.ContainerID)
+	if result.Error != nil {
+		return result.Error
+	}
+	err = w.pauseSidecars(t, true)
+	if err != nil {
+		return err
+	}
+
+	c := *t
+	c.SetState(task.Paused, task.ReasonPaused, "")
+	c.Ready = false
+	return w.Db.Put(c.ID.String(), &c)
+}
+
+// ResumeTask thaws the container of a paused task. The task has to pass its
+// readiness probe again before it is ready. It is called with mu held.
+func (w *Worker) ResumeTask(id string) error {
+	t, err := w.Db.Get(id)
+	if err != nil {
+		return err
+	}
+	if t.State != task.Paused {
+		return fmt.Errorf("task %s is %v and cannot be resumed", id, t.State)
+	}
+
+	config := task.NewConfig(t)
+	d := task.NewDocker(config)
+	result := d.Unpause(t.ContainerID)
+	if result.Error != nil {
+		return result.Error
+	}
+	err = w.pauseSidecars(t, false)
+	if err != nil {
+		return err
+	}
+
+	c := *t
+	c.SetState(task.Running, task.ReasonResumed, "")
+	c.ReadinessStatus = probe.Status{}
+	return w.Db.Put(c.ID.String(), &c)
+}
+
+// RemoveTask forgets a task that has stopped running, and removes its
+// containers in the background. It is called with mu held.
+func (w *Worker) RemoveTask(id string) error {
+	t, err := w.Db.Get(id)
+	if err != nil {
+		return err
+	}
+	if t.State != task.Failed && t.State != task.Completed {
+		return fmt.Errorf("task %s is %v and cannot be removed", id, t.State)
+	}
+
+	err = w.Db.Delete(id)
+	if err != nil {
+		return err
+	}
+
+	c := *t
+	go w.removeContainer(&c)
+	return nil
+}
+
+func (w *Worker) StopTask(t task.Task) task.DockerResult {
+	wasRunning := false
+	persisted, err := w.GetTask(t.ID.String())
+	if err == nil {
+		wasRunning = persisted.State == task.Running || persisted.State == task.Paused || persisted.State == task.Unknown
+		t.State = persisted.State
+		t.Reason = persisted.Reason
+		t.Message = persisted.Message
+		t.Transitions = persisted.Transitions
+		t.HostPorts = persisted.HostPorts
+	}
+
+	// Only a running container has anything to shut down gracefully, the
+	// container of a failed task is just removed. A task that is already
+	// stopping, or stopped since it was looked up, ends the way it was
+	// going to.
+	var stopResult task.DockerResult
+	stopped := false
+	if wasRunning {
+		stopResult, stopped = w.stopContainer(&t, true, task.ReasonStopRequested, "stopping task")
+	}
+	if !stopped {
+		if t.State == task.Stopping {
+			log.Printf("Task %s is already being stopped\n", t.ID)
+			return stopResult
+		}
+		stopResult = w.removeContainer(&t)
+	}
 	if stopResult.Error != nil {
 		log.Printf("Error stopping container %s: %v\n", t.ID, stopResult.Error)
 	}
 	t.FinishTime = time.Now().UTC()
-	t.State = task.Completed
-	w.Db.Put(t.ID.String(), &t)
+	t.SetState(task.Completed, task.ReasonStopped, "")
+	t.Ready = false
+	w.putTask(&t)
 	log.Printf("Stopped and removed container %s for task %s", t.ContainerID, t.ID)
 
-	w.TaskCount--
 	return stopResult
 }
 
+// removeContainer removes the containers and volumes of a task that is not
+// running.
+func (w *Worker) removeContainer(t *task.Task) task.DockerResult {
+	config := task.NewConfig(t)
+	d := task.NewDocker(config)
+	w.stopSidecars(t)
+	result := d.Stop(t.ContainerID)
+	if result.Error != nil {
+		log.Printf("Error removing container %s of task %s: %v\n", t.ContainerID, t.ID, result.Error)
+	}
+	w.removeVolumes(t)
+	return result
+}
+
+// GetTasks lists the stored tasks. It is called with mu held, and the tasks
+// are only to be read.
 func (w *Worker) GetTasks() []*task.Task {
 	tasks, err := w.Db.List()
 	if err != nil {
@@ -139,48 +460,150 @@ func (w *Worker) GetTasks() []*task.Task {
 	return tasks
 }
 
+// GetTask returns a copy of the task.
+func (w *Worker) GetTask(id string) (*task.Task, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	t, err := w.Db.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	c := *t
+	return &c, nil
+}
+
 func (w *Worker) InspectTask(t task.Task) task.DockerInspectResponse {
 	config := task.NewConfig(&t)
 	d := task.NewDocker(config)
 	return d.Inspect(t.ContainerID)
 }
 
+// updateTasks checks the containers of the running tasks. The containers are
+// inspected without holding mu, and a task is only changed if it is still in
+// the state it was found in.
 func (w *Worker) updateTasks() error {
+	w.mu.Lock()
 	persistedTasks, err := w.Db.List()
+	tasks := make([]task.Task, 0, len(persistedTasks))
+	for _, t := range persistedTasks {
+		tasks = append(tasks, *t)
+	}
+	w.mu.Unlock()
 	if err != nil {
 		return err
 	}
 
-	for id, t := range persistedTasks {
-		if t.State == task.Running {
+	for i := range tasks {
+		t := &tasks[i]
+		if t.State == task.Running || t.State == task.Paused || t.State == task.Unknown {
+			deadline, ok := t.ActiveDeadline()
+			if ok && time.Now().UTC().After(deadline) {
+				w.failOverdueTask(t)
+				continue
+			}
+		}
+
+		if t.State == task.Running || t.State == task.Unknown {
+
 			resp := w.InspectTask(*t)
 			if resp.Error != nil {
 				fmt.Printf("ERROR: %v\n", resp.Error)
+				w.updateTask(t.ID.String(), t.State, func(c *task.Task) {
+					c.SetState(task.Unknown, task.ReasonInspectError, resp.Error.Error())
+				})
 				continue
 			}
 
 			if resp.Container == nil {
-				log.Printf("No container for running task %d\n", id)
-				t.State = task.Failed
-				w.Db.Put(t.ID.String(), t)
+				log.Printf("No container for running task %s\n", t.ID)
+				w.updateTask(t.ID.String(), t.State, func(c *task.Task) {
+					c.FinishTime = time.Now().UTC()
+					c.SetState(task.Failed, task.ReasonContainerMissing, fmt.Sprintf("container %s not found", c.ContainerID))
+				})
 				continue
 			}
 
-			if resp.Container.State.Status == "exited" {
+			if resp.Container.State.Status == "exited" || resp.Container.State.Status == "dead" {
 				log.Printf("Container for task %s in non-running state %s", t.ID, resp.Container.State.Status)
-				t.State = task.Failed
-				w.Db.Put(t.ID.String(), t)
+				w.finishTask(t, resp.Container.State)
 				continue
 			}
 
-			t.HostPorts = resp.Container.NetworkSettings.NetworkSettingsBase.Ports
-			w.Db.Put(t.ID.String(), t)
+			name, state := w.exitedSidecar(t)
+			if state != nil {
+				log.Printf("Sidecar %s of task %s in non-running state %s", name, t.ID, state.Status)
+				w.failSidecarTask(t, name, state)
+				continue
+			}
+
+			ports := resp.Container.NetworkSettings.NetworkSettingsBase.Ports
+			w.updateTask(t.ID.String(), t.State, func(c *task.Task) {
+				if c.State == task.Unknown {
+					c.SetState(task.Running, task.ReasonStarted, "container can be inspected again")
+				}
+				c.HostPorts = ports
+			})
 		}
 	}
 
 	return nil
 }
 
+// finishTask records the outcome of a task whose container is no longer
+// running. Only jobs can complete successfully, any other task whose
+// container exits has failed. A task that was stopped meanwhile is left to
+// the path that stopped it.
+func (w *Worker) finishTask(t *task.Task, state *container.State) {
+	w.stopSidecars(t)
+	finishTime := time.Now().UTC()
+	finishedAt, err := time.Parse(time.RFC3339Nano, state.FinishedAt)
+	if err == nil {
+		finishTime = finishedAt.UTC()
+	}
+
+	config := task.NewConfig(t)
+	d := task.NewDocker(config)
+	outputs, err := d.Outputs(t.ContainerID)
+	if err != nil {
+		log.Printf("Error reading outputs of task %s: %v\n", t.ID, err)
+	}
+
+	w.updateTask(t.ID.String(), t.State, func(c *task.Task) {
+		c.Ready = false
+		c.ExitCode = state.ExitCode
+		c.FinishTime = finishTime
+		c.Outputs = outputs
+
+		if c.Kind == task.KindJob && state.ExitCode == 0 {
+			c.SetState(task.Completed, task.ReasonCompleted, "")
+			return
+		}
+
+		reason := task.ReasonError
+		message := fmt.Sprintf("container exited with code %d", state.ExitCode)
+		if state.OOMKilled {
+			reason = task.ReasonOOMKilled
+		}
+		if state.Error != "" {
+			message = fmt.Sprintf("%s: %s", message, state.Error)
+		}
+		c.SetState(task.Failed, reason, message)
+	})
+}
+
+// failSidecarTask stops a task one of whose sidecars has exited, since the
+// containers of a task run together.
+func (w *Worker) failSidecarTask(t *task.Task, name string, state *container.State) {
+	w.failTask(t, true, task.ReasonSidecarExited, fmt.Sprintf("sidecar %s exited with code %d", name, state.ExitCode))
+}
+
+// failOverdueTask stops a task that ran past its active deadline.
+func (w *Worker) failOverdueTask(t *task.Task) {
+	log.Printf("Task %s ran for longer than %ds, stopping it\n", t.ID, t.ActiveDeadlineSeconds)
+	w.failTask(t, true, task.ReasonDeadlineExceeded, fmt.Sprintf("task ran for longer than %ds", t.ActiveDeadlineSeconds))
+}
+
 func (w *Worker) UpdateTasks() {
 	for {
 		log.Println("Checking status of tasks")
@@ -189,6 +612,7 @@ func (w *Worker) UpdateTasks() {
 		if err != nil {
 			log.Printf("Error updating tasks: %v\n", err)
 		}
+		w.pruneLogs()
 		log.Println("Task updates completed")
 
 		log.Println("Sleeping for 15 seconds")
@@ -202,5 +626,10 @@ func New(name string, dbType string) *Worker {
 		Name:  name,
 		Db:    db,
 		Queue: *queue.New(),
+
+		LogDir:       "logs",
+		LogMaxSize:   10 * 1024 * 1024,
+		LogMaxFiles:  5,
+		LogRetention: 24 * time.Hour,
 	}
 }
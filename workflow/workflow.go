@@ -0,0 +1,178 @@
+package workflow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/d-bolshakov/orchestrator/task"
+	"github.com/google/uuid"
+)
+
+const (
+	Running   = "running"
+	Succeeded = "succeeded"
+	Failed    = "failed"
+)
+
+const (
+	StepWaiting   = "waiting"
+	StepRunning   = "running"
+	StepSucceeded = "succeeded"
+	StepFailed    = "failed"
+	StepSkipped   = "skipped"
+)
+
+// Conditions under which a step runs once the step it depends on finished.
+const (
+	OnSuccess = "success"
+	OnFailure = "failure"
+	Always    = "always"
+)
+
+// Workflow is a set of steps connected by dependencies. A step is started
+// once every step it depends on has finished and all of the dependency
+// conditions hold, otherwise it is skipped. The workflow fails if any of its
+// steps fails.
+type Workflow struct {
+	Name           string
+	Steps          []Step
+	State          string
+	StartTime      time.Time
+	CompletionTime time.Time
+	Message        string
+}
+
+// Step runs a single task to completion. The outputs a step's task reports
+// are passed to the tasks of the steps depending on it as environment
+// variables named OUTPUT_<STEP>_<NAME>.
+type Step struct {
+	Name      string
+	Task      task.Task
+	DependsOn []Dependency
+	State     string
+	TaskID    uuid.UUID
+	Outputs   map[string]string
+	Message   string
+}
+
+// Dependency makes a step wait for another one. Condition is one of success
+// (the default), failure or always.
+type Dependency struct {
+	Step      string
+	Condition string
+}
+
+func (w *Workflow) Validate() error {
+	if w.Name == "" {
+		return fmt.Errorf("workflow must have a name")
+	}
+	if len(w.Steps) == 0 {
+		return fmt.Errorf("workflow %s must have at least one step", w.Name)
+	}
+
+	steps := make(map[string]*Step)
+	for i := range w.Steps {
+		s := &w.Steps[i]
+		if s.Name == "" {
+			return fmt.Errorf("step %d of workflow %s must have a name", i, w.Name)
+		}
+		if _, ok := steps[s.Name]; ok {
+			return fmt.Errorf("workflow %s has more than one step named %s", w.Name, s.Name)
+		}
+		if s.Task.Image == "" {
+			return fmt.Errorf("step %s of workflow %s must have an image", s.Name, w.Name)
+		}
+		// Steps run as job tasks.
+		t := s.Task
+		t.Kind = task.KindJob
+		err := t.Validate()
+		if err != nil {
+			return fmt.Errorf("step %s of workflow %s: %v", s.Name, w.Name, err)
+		}
+		steps[s.Name] = s
+	}
+
+	for _, s := range w.Steps {
+		for _, d := range s.DependsOn {
+			if _, ok := steps[d.Step]; !ok {
+				return fmt.Errorf("step %s of workflow %s depends on unknown step %s", s.Name, w.Name, d.Step)
+			}
+
+			switch d.Condition {
+			case "", OnSuccess, OnFailure, Always:
+			default:
+				return fmt.Errorf("step %s of workflow %s has unknown condition %s", s.Name, w.Name, d.Condition)
+			}
+		}
+	}
+
+	return w.checkCycles(steps)
+}
+
+func (w *Workflow) checkCycles(steps map[string]*Step) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[string]int)
+
+	var visit func(s *Step) error
+	visit = func(s *Step) error {
+		switch marks[s.Name] {
+		case visiting:
+			return fmt.Errorf("workflow %s has a dependency cycle through step %s", w.Name, s.Name)
+		case visited:
+			return nil
+		}
+
+		marks[s.Name] = visiting
+		for _, d := range s.DependsOn {
+			err := visit(steps[d.Step])
+			if err != nil {
+				return err
+			}
+		}
+		marks[s.Name] = visited
+		return nil
+	}
+
+	for i := range w.Steps {
+		err := visit(&w.Steps[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Workflow) Step(name string) *Step {
+	for i := range w.Steps {
+		if w.Steps[i].Name == name {
+			return &w.Steps[i]
+		}
+	}
+
+	return nil
+}
+
+func (w *Workflow) Finished() bool {
+	return w.State == Succeeded || w.State == Failed
+}
+
+func (s *Step) Finished() bool {
+	return s.State == StepSucceeded || s.State == StepFailed || s.State == StepSkipped
+}
+
+// Satisfied reports whether the condition holds for a finished step.
+func (d Dependency) Satisfied(s *Step) bool {
+	switch d.Condition {
+	case Always:
+		return true
+	case OnFailure:
+		return s.State == StepFailed
+	default:
+		return s.State == StepSucceeded
+	}
+}
@@ -0,0 +1,43 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/d-bolshakov/orchestrator/task"
+)
+
+func TestWorkflowValidate(t *testing.T) {
+	step := func(name string, deps ...string) Step {
+		s := Step{Name: name, Task: task.Task{Image: "busybox"}}
+		for _, d := range deps {
+			s.DependsOn = append(s.DependsOn, Dependency{Step: d})
+		}
+		return s
+	}
+	always := step("a")
+	always.Task.RestartPolicy = task.RestartPolicy{Mode: task.RestartAlways}
+	badCondition := step("b", "a")
+	badCondition.DependsOn[0].Condition = "sometimes"
+
+	tests := []struct {
+		name    string
+		steps   []Step
+		wantErr bool
+	}{
+		{"chain", []Step{step("a"), step("b", "a"), step("c", "a", "b")}, false},
+		{"no steps", nil, true},
+		{"duplicate step", []Step{step("a"), step("a")}, true},
+		{"unknown dependency", []Step{step("a", "b")}, true},
+		{"unknown condition", []Step{step("a"), badCondition}, true},
+		{"cycle", []Step{step("a", "c"), step("b", "a"), step("c", "b")}, true},
+		{"restart always", []Step{always}, true},
+	}
+
+	for _, tt := range tests {
+		w := Workflow{Name: "w", Steps: tt.steps}
+		err := w.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}